@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	. "github.com/nbjahan/go-launchbar"
+	"github.com/nbjahan/go-launchbar/bridge"
+)
+
+// actionURLs are the routes of the URL scheme, e.g.
+//
+//	x-launchbar:action/nbjahan.launchbar.livedic/lookup?hello
+//	x-launchbar:action/nbjahan.launchbar.livedic/translate?from=en&to=de&q=bank
+var actionURLs = NewRouter().
+	Handle("lookup", func(a *Action, u *ActionURL) error {
+		if u.Arg == "" {
+			return errors.New("lookup: nothing to look up, e.g. lookup?hello")
+		}
+		a.Perform(u.Arg)
+		return nil
+	}).
+	Handle("spell", func(a *Action, u *ActionURL) error {
+		if u.Arg == "" {
+			return errors.New("spell: no word to check, e.g. spell?recieve")
+		}
+		text, err := spellingText(u.Arg)
+		if err != nil {
+			return err
+		}
+		a.LargeType(text)
+		return nil
+	}).
+	Handle("thesaurus", func(a *Action, u *ActionURL) error {
+		if u.Arg == "" {
+			return errors.New("thesaurus: no word, e.g. thesaurus?happy")
+		}
+		a.Perform("syn:" + u.Arg)
+		return nil
+	}).
+	Handle("translate", func(a *Action, u *ActionURL) error {
+		if u.Arg == "" || u.Get("to") == "" {
+			return errors.New("translate: expected translate?from=en&to=de&q=word")
+		}
+		from := u.Get("from")
+		if from == "" {
+			from = "auto"
+		}
+		return bridge.Run(bridge.OpenLocation(translateURL(from, u.Get("to"), u.Arg)))
+	}).
+	Handle("history", func(a *Action, u *ActionURL) error {
+		a.ShowView("history")
+		return nil
+	}).
+	Handle("review", func(a *Action, u *ActionURL) error {
+		a.ShowView("review")
+		return nil
+	})
+
+// spellingText is the result of the spell check of the word, like dict spell
+func spellingText(word string) (string, error) {
+	correct, err := checkSpelling(word)
+	if err != nil {
+		return "", err
+	}
+	if correct {
+		return word + " ✓", nil
+	}
+	guesses := spell(word)
+	rankByHistory(word, guesses)
+	if len(guesses) > 5 {
+		guesses = guesses[:5]
+	}
+	if len(guesses) == 0 {
+		return word + " ✗", nil
+	}
+	return fmt.Sprintf("%s ✗ did you mean %s?", word, strings.Join(guesses, ", ")), nil
+}
+
+// translateURL returns the Google Translate page of the text
+func translateURL(from, to, text string) string {
+	v := url.Values{"sl": {from}, "tl": {to}, "text": {text}, "op": {"translate"}}
+	return "https://translate.google.com/?" + v.Encode()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/nbjahan/go-launchbar"
+	"github.com/nbjahan/go-launchbar/launchbartest"
+)
+
+// fakeBackend answers the lookups from its maps
+type fakeBackend struct {
+	words   map[string]string // the definitions
+	guesses map[string][]string
+	err     error            // returned by all the lookups if it's not nil
+	errs    map[string]error // returned by the lookups of a word
+	wait    func(word string)
+}
+
+func (b *fakeBackend) Source() string { return "fake" }
+
+func (b *fakeBackend) Define(word string) (string, string, error) {
+	if b.wait != nil {
+		b.wait(word)
+	}
+	if b.err != nil {
+		return word, "", b.err
+	}
+	if err := b.errs[word]; err != nil {
+		return word, "", err
+	}
+	return word, b.words[word], nil
+}
+
+func (b *fakeBackend) Spell(word, lang string) ([]string, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := b.errs[word]; err != nil {
+		return nil, err
+	}
+	return b.guesses[word], nil
+}
+
+func (b *fakeBackend) Check(word, lang string) (bool, error) {
+	if b.err != nil {
+		return false, b.err
+	}
+	_, ok := b.words[word]
+	return ok, nil
+}
+
+// useFakeBackend makes b the "fake" backend and the current one until the
+// returned func is called
+func useFakeBackend(b *fakeBackend) func() {
+	registerBackend("fake", func(*Config) backend { return b })
+	prev, prevLang, prevKey := dictBackend, dictLang, dictBackendKey
+	dictBackend, dictLang, dictBackendKey = b, "", "fake"
+	return func() {
+		delete(backends, "fake")
+		delete(backendConfig, "fake")
+		dictBackend, dictLang, dictBackendKey = prev, prevLang, prevKey
+	}
+}
+
+const livedicID = "nbjahan.launchbar.livedic"
+
+// runActionURL runs the action URL in a simulated LaunchBar with the fake
+// backend and returns the AppleScripts the action ran
+func runActionURL(t *testing.T, b *fakeBackend, route string) []string {
+	done := useFakeBackend(b)
+	defer done()
+	lb, err := launchbartest.New(map[string]interface{}{"CFBundleIdentifier": livedicID, "CFBundleName": "Dictionary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lb.Close()
+	if err := ioutil.WriteFile(path.Join(lb.SupportPath, "config.json"), []byte(`{"backend": "fake"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lb.ScriptType = "actionURL"
+	items, err := lb.Run(runAction, "x-launchbar:action/"+livedicID+"/"+route)
+	if err != nil {
+		t.Fatalf("%s: %v", route, err)
+	}
+	if len(items) != 0 {
+		t.Errorf("%s returned %d items, want none", route, len(items))
+	}
+	return lb.Scripts()
+}
+
+func tellLaunchBar(cmd string) string {
+	return "tell application \"LaunchBar\"\n\t" + cmd + "\nend tell"
+}
+
+func TestActionURLs(t *testing.T) {
+	b := &fakeBackend{
+		words:   map[string]string{"hello": "hello | | exclamation used as a greeting", "receive": "receive | | verb be given"},
+		guesses: map[string][]string{"recieve": {"receive", "relieve"}},
+	}
+	showView := tellLaunchBar("remain active\n\tperform action \"Dictionary: Define (Live)\"")
+	tests := []struct {
+		route   string
+		scripts []string
+	}{
+		{"lookup?hello%20world", []string{tellLaunchBar(`perform action "` + livedicID + `" with string "hello world"`)}},
+		{"lookup?q=caf%C3%A9", []string{tellLaunchBar(`perform action "` + livedicID + `" with string "café"`)}},
+		{"spell?hello", []string{tellLaunchBar(`display in large type "hello ✓"`)}},
+		{"spell?recieve", []string{tellLaunchBar(`display in large type "recieve ✗ did you mean receive, relieve?"`)}},
+		{"thesaurus?happy", []string{tellLaunchBar(`perform action "` + livedicID + `" with string "syn:happy"`)}},
+		{
+			"translate?from=en&to=de&q=bank%20account",
+			[]string{`open location "https://translate.google.com/?op=translate&sl=en&text=bank+account&tl=de"`},
+		},
+		{
+			"translate?to=fr&q=rock+%26+roll",
+			[]string{`open location "https://translate.google.com/?op=translate&sl=auto&text=rock+%26+roll&tl=fr"`},
+		},
+		{"history", []string{showView}},
+		{"review", []string{showView}},
+		// the errors are shown in large type
+		{"lookup", []string{tellLaunchBar(`display in large type "lookup: nothing to look up, e.g. lookup?hello"`)}},
+		{"translate?q=bank", []string{tellLaunchBar(`display in large type "translate: expected translate?from=en&to=de&q=word"`)}},
+		{"define?hello", []string{tellLaunchBar(`display in large type "unknown action URL \"define\" (available: history, lookup, review, spell, thesaurus, translate)"`)}},
+	}
+	for _, tt := range tests {
+		scripts := runActionURL(t, b, tt.route)
+		if strings.Join(scripts, "\n---\n") != strings.Join(tt.scripts, "\n---\n") {
+			t.Errorf("%s ran\n%s\nwant\n%s", tt.route, strings.Join(scripts, "\n---\n"), strings.Join(tt.scripts, "\n---\n"))
+		}
+	}
+}
+
+func TestActionURLViews(t *testing.T) {
+	done := useFakeBackend(&fakeBackend{})
+	defer done()
+	lb, err := launchbartest.New(map[string]interface{}{"CFBundleIdentifier": livedicID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lb.Close()
+	lb.ScriptType = "actionURL"
+	for _, view := range []string{"history", "review"} {
+		if _, err := lb.Run(runAction, "x-launchbar:action/"+livedicID+"/"+view); err != nil {
+			t.Fatal(err)
+		}
+		if got := lb.Config().GetString("view"); got != view {
+			t.Errorf("%s opened the %q view", view, got)
+		}
+	}
+}
+
+func TestActionURLSpellError(t *testing.T) {
+	b := &fakeBackend{err: errors.New("dictd is down")}
+	scripts := runActionURL(t, b, "spell?hello")
+	if want := tellLaunchBar(`display in large type "dictd is down"`); len(scripts) != 1 || scripts[0] != want {
+		t.Errorf("spell with a failing backend ran %q, want %q", scripts, want)
+	}
+}
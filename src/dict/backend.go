@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	. "github.com/nbjahan/go-launchbar"
+)
+
+// backend is a dictionary and a spell checker the lookups are answered by
+type backend interface {
+	// Source is the name of the dictionary shown in the QuickLook pages and
+	// the exports
+	Source() string
+	// Define returns the matched headword and the definition text, "" if
+	// the word is not found
+	Define(word string) (string, string, error)
+	// Spell returns the guesses for the word in lang ("" for the default)
+	Spell(word, lang string) ([]string, error)
+	// Check returns true if the word is spelled correctly
+	Check(word, lang string) (bool, error)
+}
+
+// backends are the available backends by name, built from the config
+var backends = make(map[string]func(c *Config) backend)
+
+// backendConfig are the config keys each backend is built from
+var backendConfig = make(map[string][]string)
+
+// registerBackend adds the backend. The keys are the config it's built from,
+// a change of them rebuilds it.
+func registerBackend(name string, fn func(c *Config) backend, keys ...string) {
+	backends[name] = fn
+	backendConfig[name] = keys
+}
+
+func backendNames() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultBackend is used when the backend config is empty
+func defaultBackend() string {
+	if runtime.GOOS == "darwin" {
+		return "apple"
+	}
+	return "dictd"
+}
+
+var (
+	dictBackend backend
+	dictLang    string
+	// dictBackendKey is the name and the config dictBackend was built from
+	dictBackendKey string
+)
+
+// useBackend selects the backend and the spelling language of the lookups.
+// The current backend is kept if its config didn't change, so the daemon and
+// the TUI reuse its connections, and closed if it's replaced.
+func useBackend(name, lang string, c *Config) error {
+	if name == "" {
+		name = defaultBackend()
+	}
+	fn, ok := backends[name]
+	if !ok {
+		return fmt.Errorf("unknown backend %q (available: %v)", name, backendNames())
+	}
+	key := name
+	for _, k := range backendConfig[name] {
+		key += "\x00" + c.GetString(k)
+	}
+	if dictBackend == nil || key != dictBackendKey {
+		closeBackend()
+		dictBackend, dictBackendKey = fn(c), key
+	}
+	dictLang = lang
+	return nil
+}
+
+// closeBackend closes the current backend if it holds any connections
+func closeBackend() {
+	if c, ok := dictBackend.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			logError(err)
+		}
+	}
+	dictBackend, dictBackendKey = nil, ""
+}
+
+// source returns the name of the dictionary answering the lookups
+func source() string {
+	if dictBackend == nil {
+		return ""
+	}
+	return dictBackend.Source()
+}
+
+func define(word string) (string, string) {
+	if dictBackend == nil {
+		return word, ""
+	}
+	headword, def, err := dictBackend.Define(word)
+	if err != nil {
+		logError(err)
+	}
+	return headword, def
+}
+
+func spell(word string) []string {
+	if dictBackend == nil {
+		return nil
+	}
+	guesses, err := dictBackend.Spell(word, dictLang)
+	if err != nil {
+		logError(err)
+	}
+	return guesses
+}
+
+// checkSpelling returns true if the word is spelled correctly or is in the
+// personal word list. The word is neither correct nor misspelled if the
+// backend fails.
+func checkSpelling(word string) (bool, error) {
+	if personal.Has(word) {
+		return true, nil
+	}
+	if dictBackend == nil {
+		return false, errors.New("no dictionary backend")
+	}
+	return dictBackend.Check(word, dictLang)
+}
+
+// language returns the spelling language for the thesaurus and the web
+// dictionaries: the lang config or --lang, else the system's from $LANG
+func language() string {
+	if dictLang != "" {
+		return dictLang
+	}
+	lang := strings.SplitN(os.Getenv("LANG"), ".", 2)[0]
+	if lang == "" || lang == "C" || lang == "POSIX" {
+		return "en_US"
+	}
+	return lang
+}
+
+// errorHandler receives the logged errors instead of stderr when it's set,
+// e.g. by the TUI which owns the terminal
+var errorHandler func(err error)
+
+// logError logs to the action's log, or to stderr on the command line
+func logError(err error) {
+	if errorHandler != nil {
+		errorHandler(err)
+		return
+	}
+	if pb != nil {
+		pb.Logger.Println(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+}
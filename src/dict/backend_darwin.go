@@ -0,0 +1,127 @@
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework Cocoa -framework AppKit
+#import <Foundation/Foundation.h>
+#import <AppKit/NSSpellChecker.h>
+
+const char*
+define(const char *s, int *start, int *len) {
+  NSString *word = [[NSString alloc] initWithCString:s encoding:NSUTF8StringEncoding];
+  CFRange termRange = DCSGetTermRangeInString(NULL, (CFStringRef)word, 0);
+
+  NSString *definition = @"";
+  *start = termRange.location;
+  *len = termRange.length;
+
+  if(*start != -1) {
+    definition = (NSString*)DCSCopyTextDefinition(NULL, (CFStringRef)word, termRange);
+    NSString *first_part = [word substringToIndex: *start];
+    NSString *second_part = [word substringWithRange: NSMakeRange(*start, *len)];
+    *start = [first_part lengthOfBytesUsingEncoding: NSUTF8StringEncoding];
+    *len = [second_part lengthOfBytesUsingEncoding: NSUTF8StringEncoding];
+  }
+
+  return [definition UTF8String];
+}
+
+NSString*
+spellLanguage(NSSpellChecker *spellChecker, const char *lang) {
+  if (lang != NULL && strlen(lang) > 0) {
+    return [NSString stringWithUTF8String:lang];
+  }
+  return [spellChecker language];
+}
+
+const char **
+spell(const char * s, const char *lang, int *n) {
+  NSString * term = [[NSString alloc] initWithCString:s encoding:NSUTF8StringEncoding];
+  NSSpellChecker *spellChecker = [NSSpellChecker sharedSpellChecker];
+  NSArray *guesses = [spellChecker guessesForWordRange:NSMakeRange(0, [term length])
+                                                  inString:term
+                                                  language:spellLanguage(spellChecker, lang)
+                                    inSpellDocumentWithTag:0];
+  int count = [guesses count];
+  *n = count;
+  const char** cArray = malloc(sizeof(const char *) * count);
+  for (int i=0; i<count; ++i) {
+    cArray[i] = [[guesses objectAtIndex:i] UTF8String];
+  }
+  return cArray;
+}
+
+int
+check(const char * s, const char *lang) {
+  NSString * term = [[NSString alloc] initWithCString:s encoding:NSUTF8StringEncoding];
+  NSSpellChecker *spellChecker = [NSSpellChecker sharedSpellChecker];
+  NSRange r = [spellChecker checkSpellingOfString:term
+                                       startingAt:0
+                                         language:spellLanguage(spellChecker, lang)
+                                             wrap:NO
+                                   inSpellDocumentWithTag:0
+                                        wordCount:NULL];
+  return r.location == NSNotFound;
+}
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	. "github.com/nbjahan/go-launchbar"
+)
+
+// appleBackend looks up the words in Dictionary.app and checks the spelling
+// with the system spell checker.
+type appleBackend struct{}
+
+// spellMutex serializes the calls to the shared NSSpellChecker, which is not
+// safe for concurrent use
+var spellMutex sync.Mutex
+
+func init() {
+	registerBackend("apple", func(c *Config) backend { return appleBackend{} })
+}
+
+func (appleBackend) Source() string { return "Dictionary.app" }
+
+func (appleBackend) Define(word string) (string, string, error) {
+	cs := C.CString(word)
+	defer C.free(unsafe.Pointer(cs))
+	var start, l C.int
+	cdef := C.define(cs, &start, &l)
+	if start != -1 {
+		word = word[start : start+l]
+	}
+	return word, C.GoString(cdef), nil
+}
+
+func (appleBackend) Spell(word, lang string) ([]string, error) {
+	cs := C.CString(word)
+	defer C.free(unsafe.Pointer(cs))
+	cl := C.CString(lang)
+	defer C.free(unsafe.Pointer(cl))
+	var n C.int
+	spellMutex.Lock()
+	r := C.spell(cs, cl, &n)
+	spellMutex.Unlock()
+	ar := ((*[1 << 30]*C.char)(unsafe.Pointer(r)))[:n]
+	defer C.free(unsafe.Pointer(r))
+	guesses := make([]string, 0)
+	for _, s := range ar {
+		guesses = append(guesses, C.GoString(s))
+	}
+	return guesses, nil
+}
+
+func (appleBackend) Check(word, lang string) (bool, error) {
+	cs := C.CString(word)
+	defer C.free(unsafe.Pointer(cs))
+	cl := C.CString(lang)
+	defer C.free(unsafe.Pointer(cl))
+	spellMutex.Lock()
+	defer spellMutex.Unlock()
+	return C.check(cs, cl) != 0, nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/nbjahan/go-launchbar"
+)
+
+// dictdBackend is a DICT protocol (RFC 2229) client, e.g. for dict.org or a
+// local dictd with the WordNet database. The guesses come from the server's
+// "lev" match strategy, so the spelling language is the database's.
+//
+// The connection is kept open for the next lookups, which the action, the
+// language server and the batches make many of.
+type dictdBackend struct {
+	server   string
+	database string
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	text *textproto.Conn
+}
+
+func init() {
+	registerBackend("dictd", func(c *Config) backend {
+		b := &dictdBackend{
+			server:   c.GetString("dictServer"),
+			database: c.GetString("dictDatabase"),
+			timeout:  10 * time.Second,
+		}
+		if b.server == "" {
+			b.server = "dict.org:2628"
+		}
+		if !strings.Contains(b.server, ":") {
+			b.server += ":2628"
+		}
+		if b.database == "" {
+			b.database = "wn"
+		}
+		return b
+	}, "dictServer", "dictDatabase")
+}
+
+func (b *dictdBackend) Source() string { return "dict://" + b.server + "/" + b.database }
+
+// dial connects to the server and reads its banner
+func (b *dictdBackend) dial() error {
+	conn, err := net.DialTimeout("tcp", b.server, b.timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(b.timeout))
+	c := textproto.NewConn(conn)
+	if _, _, err := c.ReadCodeLine(220); err != nil {
+		c.Close()
+		return err
+	}
+	b.conn, b.text = conn, c
+	return nil
+}
+
+// do runs the command on the open connection, or on a new one if there's none
+// or the server closed it since. The connection is dropped if the command
+// fails with anything but a status response.
+func (b *dictdBackend) do(cmd func(c *textproto.Conn) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for retry := b.text != nil; ; retry = false {
+		if b.text == nil {
+			if err := b.dial(); err != nil {
+				return err
+			}
+		}
+		b.conn.SetDeadline(time.Now().Add(b.timeout))
+		err := cmd(b.text)
+		if _, ok := err.(*textproto.Error); err == nil || ok {
+			return err
+		}
+		b.text.Close()
+		b.conn, b.text = nil, nil
+		if !retry {
+			return err
+		}
+	}
+}
+
+// Close closes the open connection
+func (b *dictdBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.text == nil {
+		return nil
+	}
+	b.text.Cmd("QUIT")
+	err := b.text.Close()
+	b.conn, b.text = nil, nil
+	return err
+}
+
+func (b *dictdBackend) Define(word string) (string, string, error) {
+	headword, def := word, ""
+	err := b.do(func(c *textproto.Conn) error {
+		headword, def = word, ""
+		if _, err := c.Cmd("DEFINE %s %s", b.database, dictQuote(word)); err != nil {
+			return err
+		}
+		if _, _, err := c.ReadCodeLine(150); err != nil {
+			if isNoMatch(err) {
+				return nil
+			}
+			return err
+		}
+		// 151 "word" database "database name" for each definition, then
+		// 250 ok. Only the first definition is used.
+		for n := 0; ; n++ {
+			code, msg, err := c.ReadCodeLine(0)
+			if err != nil {
+				return err
+			}
+			if code == 250 {
+				return nil
+			}
+			if code != 151 {
+				return fmt.Errorf("unexpected response %d %s", code, msg)
+			}
+			lines, err := c.ReadDotLines()
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				continue
+			}
+			if fields := dictFields(msg); len(fields) > 0 {
+				headword = fields[0]
+			}
+			def = wordnetText(headword, lines)
+		}
+	})
+	if err != nil {
+		return word, "", err
+	}
+	return headword, def, nil
+}
+
+func (b *dictdBackend) match(word, strategy string) ([]string, error) {
+	var words []string
+	err := b.do(func(c *textproto.Conn) error {
+		words = nil
+		if _, err := c.Cmd("MATCH %s %s %s", b.database, strategy, dictQuote(word)); err != nil {
+			return err
+		}
+		if _, _, err := c.ReadCodeLine(152); err != nil {
+			if isNoMatch(err) {
+				return nil
+			}
+			return err
+		}
+		lines, err := c.ReadDotLines()
+		if err != nil {
+			return err
+		}
+		if _, _, err := c.ReadCodeLine(250); err != nil {
+			return err
+		}
+		words = make([]string, 0, len(lines))
+		seen := make(map[string]bool)
+		for _, l := range lines {
+			// database "word"
+			if fields := dictFields(l); len(fields) == 2 && !seen[fields[1]] {
+				seen[fields[1]] = true
+				words = append(words, fields[1])
+			}
+		}
+		return nil
+	})
+	return words, err
+}
+
+func (b *dictdBackend) Spell(word, lang string) ([]string, error) {
+	return b.match(word, "lev")
+}
+
+func (b *dictdBackend) Check(word, lang string) (bool, error) {
+	words, err := b.match(word, "exact")
+	return len(words) > 0, err
+}
+
+// isNoMatch reports the 552 no match response
+func isNoMatch(err error) bool {
+	e, ok := err.(*textproto.Error)
+	return ok && e.Code == 552
+}
+
+func dictQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// dictFields splits a response line into its atoms and quoted strings
+func dictFields(s string) []string {
+	fields := make([]string, 0)
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] != '"' {
+			end := strings.IndexByte(s, ' ')
+			if end == -1 {
+				end = len(s)
+			}
+			fields = append(fields, s[:end])
+			s = s[end:]
+			continue
+		}
+		var f []byte
+		n := 1
+		for ; n < len(s) && s[n] != '"'; n++ {
+			if s[n] == '\\' && n+1 < len(s) {
+				n++
+			}
+			f = append(f, s[n])
+		}
+		fields = append(fields, string(f))
+		if n < len(s) {
+			n++
+		}
+		s = s[n:]
+	}
+	return fields
+}
+
+var wordnetPartsOfSpeech = map[string]string{"n": "noun", "v": "verb", "adj": "adjective", "adv": "adverb"}
+
+// wordnetSenseRegexp matches the start of a WordNet sense: "n 1: ", "2: " or
+// "adj : "
+var wordnetSenseRegexp = regexp.MustCompile(`^(?:(n|v|adj|adv)\s+)?(\d+)?\s*:\s+`)
+
+var wordnetRefRegexp = regexp.MustCompile(`\s*\[(syn|ant|also|see also):[^\]]*\]`)
+
+// wordnetText converts a WordNet definition to the layout of Dictionary.app
+// so parseEntry can read it:
+//
+//	bank
+//	    n 1: sloping land (especially the slope beside a body of water);
+//	         "they pulled the canoe up on the bank"
+//	    2: a financial institution
+//
+// becomes
+//
+//	bank | | noun 1 sloping land (especially the slope beside a body of
+//	water): they pulled the canoe up on the bank; 2 a financial institution;
+//
+// Other databases are returned as they are.
+func wordnetText(headword string, lines []string) string {
+	senses := make([]string, 0)
+	for n, l := range lines {
+		l = strings.TrimSpace(l)
+		if n == 0 && strings.EqualFold(l, headword) || l == "" {
+			continue
+		}
+		if wordnetSenseRegexp.MatchString(l) || len(senses) == 0 {
+			senses = append(senses, l)
+		} else {
+			senses[len(senses)-1] += " " + l
+		}
+	}
+	if len(senses) == 0 || !wordnetSenseRegexp.MatchString(senses[0]) {
+		return strings.TrimSpace(strings.Join(lines, "\n"))
+	}
+
+	out := []string{headword, "|", "|"}
+	number := 0
+	for _, s := range senses {
+		m := wordnetSenseRegexp.FindStringSubmatch(s)
+		if m[1] != "" {
+			if number > 0 {
+				out = append(out, "▶")
+			}
+			out = append(out, wordnetPartsOfSpeech[m[1]])
+			number = 0
+		}
+		number++
+		if m[2] != "" {
+			out = append(out, fmt.Sprint(number))
+		}
+		// the ; ends the sense for parseSenses
+		out = append(out, wordnetSense(s[len(m[0]):])+";")
+	}
+	return strings.Join(out, " ")
+}
+
+// wordnetSense turns `definition; "example"; "example"` into
+// "definition: example | example"
+func wordnetSense(s string) string {
+	s = wordnetRefRegexp.ReplaceAllString(s, "")
+	s = strings.NewReplacer("{", "", "}", "").Replace(s)
+	examples := make([]string, 0)
+	def := s
+	if i := strings.Index(s, `"`); i != -1 {
+		def = s[:i]
+		for _, ex := range strings.Split(s[i:], `"`) {
+			if ex = strings.Trim(ex, " ;"); ex != "" {
+				examples = append(examples, ex)
+			}
+		}
+	}
+	def = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(def), ";"))
+	if len(examples) == 0 {
+		return def
+	}
+	return def + ": " + strings.Join(examples, " | ")
+}
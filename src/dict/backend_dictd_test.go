@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/nbjahan/go-launchbar"
+)
+
+// fakeDictd answers MATCH exact for the words, and closes the connections
+// after the number of commands if it's not 0
+func fakeDictd(t *testing.T, words map[string]bool, commands int) (*dictdBackend, *int32, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&conns, 1)
+			go func(conn net.Conn) {
+				defer conn.Close()
+				fmt.Fprint(conn, "220 fake <auth.mime> <1@x>\r\n")
+				r := bufio.NewScanner(conn)
+				for n := 1; r.Scan(); n++ {
+					fields := strings.Fields(r.Text())
+					switch {
+					case fields[0] == "QUIT":
+						fmt.Fprint(conn, "221 bye\r\n")
+						return
+					case len(fields) == 4 && fields[0] == "MATCH" && words[strings.Trim(fields[3], `"`)]:
+						fmt.Fprintf(conn, "152 1 matches found\r\nwn %s\r\n.\r\n250 ok\r\n", fields[3])
+					default:
+						fmt.Fprint(conn, "552 no match\r\n")
+					}
+					if n == commands {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	b := &dictdBackend{server: l.Addr().String(), database: "wn", timeout: time.Second}
+	return b, &conns, func() { l.Close() }
+}
+
+func TestDictdReusesConnection(t *testing.T) {
+	b, conns, done := fakeDictd(t, map[string]bool{"hello": true}, 0)
+	defer done()
+	for _, word := range []string{"hello", "helo", "hello"} {
+		ok, err := b.Check(word, "")
+		if err != nil || ok != (word == "hello") {
+			t.Errorf("Check(%q) = %t, %v", word, ok, err)
+		}
+	}
+	if n := atomic.LoadInt32(conns); n != 1 {
+		t.Errorf("%d connections, want 1", n)
+	}
+}
+
+func TestDictdRedials(t *testing.T) {
+	// the server closes the connection after each command, like an idle
+	// timeout between the lookups
+	b, conns, done := fakeDictd(t, map[string]bool{"hello": true}, 1)
+	defer done()
+	for n := 0; n < 3; n++ {
+		if ok, err := b.Check("hello", ""); !ok || err != nil {
+			t.Fatalf("Check #%d = %t, %v", n, ok, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(conns); n != 3 {
+		t.Errorf("%d connections, want 3", n)
+	}
+}
+
+func TestUseBackendKeepsDictd(t *testing.T) {
+	fake, conns, done := fakeDictd(t, map[string]bool{"hello": true}, 0)
+	defer done()
+	prev, prevKey := dictBackend, dictBackendKey
+	defer func() { dictBackend, dictBackendKey = prev, prevKey }()
+	dictBackend, dictBackendKey = nil, ""
+
+	dir, err := ioutil.TempDir("", "dict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := func(server string) *Config {
+		p := path.Join(dir, "config.json")
+		if err := ioutil.WriteFile(p, []byte(`{"dictServer": "`+server+`"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return NewConfig(dir)
+	}
+
+	// the requests of the daemon, each one loads the config again
+	c := config(fake.server)
+	var first backend
+	for n := 0; n < 3; n++ {
+		if err := useBackend("dictd", "", c); err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = dictBackend
+		} else if dictBackend != first {
+			t.Errorf("request %d built a new backend", n)
+		}
+		if ok, err := dictBackend.Check("hello", ""); !ok || err != nil {
+			t.Fatalf("Check #%d = %t, %v", n, ok, err)
+		}
+	}
+	if n := atomic.LoadInt32(conns); n != 1 {
+		t.Errorf("%d connections, want 1", n)
+	}
+
+	// a new server replaces the backend and closes the old one
+	if err := useBackend("dictd", "", config("127.0.0.1:1")); err != nil {
+		t.Fatal(err)
+	}
+	if dictBackend == first {
+		t.Error("the server change kept the backend")
+	}
+	if first.(*dictdBackend).text != nil {
+		t.Error("the replaced backend is still connected")
+	}
+	closeBackend()
+}
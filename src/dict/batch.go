@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// batchRecord is the lookup result of a line of the batch input
+type batchRecord struct {
+	Line        int      `json:"line"`
+	Term        string   `json:"term"`
+	Headword    string   `json:"headword,omitempty"`
+	Definition  string   `json:"definition,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// batchColumns are the columns of the TSV output
+var batchColumns = []string{"line", "term", "headword", "definition", "source", "suggestions", "error"}
+
+func (r batchRecord) fields() []string {
+	return []string{
+		fmt.Sprint(r.Line), r.Term, r.Headword, r.Definition, r.Source,
+		strings.Join(r.Suggestions, ", "), r.Error,
+	}
+}
+
+// lookupTerm defines the term, or spells it when it's not defined. Unlike
+// define() and spell() the backend errors are returned in the record.
+func lookupTerm(line int, term string) batchRecord {
+	r := batchRecord{Line: line, Term: term}
+	headword, def, err := dictBackend.Define(term)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if def = strings.TrimSpace(def); def != "" {
+		e := parseEntry(def)
+		if e.Headword == "" {
+			e.Headword = headword
+		}
+		r.Headword = e.Headword
+		r.Definition = summary(e)
+		r.Source = source()
+		return r
+	}
+	if personal.Has(term) {
+		return r
+	}
+	guesses, err := dictBackend.Spell(term, dictLang)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	rankByHistory(term, guesses)
+	r.Suggestions = guesses
+	return r
+}
+
+type batchJob struct {
+	n    int // position in the output
+	line int
+	term string
+}
+
+type batchResult struct {
+	n      int
+	record batchRecord
+}
+
+// runBatch looks up the terms of rd, one per line, with the workers and
+// calls emit with the records in the input order. Empty lines are skipped.
+func runBatch(rd io.Reader, workers int, emit func(batchRecord)) error {
+	jobs := make(chan batchJob)
+	results := make(chan batchResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results <- batchResult{j.n, lookupTerm(j.line, j.term)}
+			}
+		}()
+	}
+
+	// emit the records in order, holding the ones that finish early
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pending := make(map[int]batchRecord)
+		next := 0
+		for r := range results {
+			pending[r.n] = r.record
+			for rec, ok := pending[next]; ok; rec, ok = pending[next] {
+				emit(rec)
+				delete(pending, next)
+				next++
+			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for line := 1; scanner.Scan(); line++ {
+		term := strings.TrimSpace(scanner.Text())
+		if term == "" {
+			continue
+		}
+		jobs <- batchJob{n, line, term}
+		n++
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+	<-done
+	return scanner.Err()
+}
+
+func batchCommand(args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	format := fs.String("format", "tsv", "output format: tsv or jsonl")
+	workers := fs.Int("workers", 4, "number of concurrent lookups")
+	backend := fs.String("backend", "", "dictionary backend: "+strings.Join(backendNames(), ", ")+" (default: the backend config)")
+	lang := fs.String("lang", "", "spelling language, e.g. en_GB (default: the lang config)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dict batch [-format tsv|jsonl] [-workers n] [-backend name] [-lang code] [file]")
+		fmt.Fprintln(os.Stderr, "Looks up one term per line of the file or stdin.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "tsv" && *format != "jsonl" || *workers < 1 || fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+	if _, err := initCommand(*backend, *lang); err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 2
+	}
+
+	rd := io.Reader(os.Stdin)
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		fd, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+			return 2
+		}
+		defer fd.Close()
+		rd = fd
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if *format == "tsv" {
+		fmt.Fprintln(out, strings.Join(batchColumns, "\t"))
+	}
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	status := 0
+	err := runBatch(rd, *workers, func(r batchRecord) {
+		if r.Error != "" {
+			fmt.Fprintf(os.Stderr, "dict: line %d: %s\n", r.Line, r.Error)
+		}
+		if r.Error != "" || r.Definition == "" {
+			status = 1
+		}
+		if *format == "jsonl" {
+			enc.Encode(r)
+			return
+		}
+		fields := r.fields()
+		for i, f := range fields {
+			fields[i] = tsvField(f)
+		}
+		fmt.Fprintln(out, strings.Join(fields, "\t"))
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 1
+	}
+	return status
+}
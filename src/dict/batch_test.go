@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunBatchOrder(t *testing.T) {
+	b := &fakeBackend{
+		words:   map[string]string{},
+		guesses: map[string][]string{},
+		errs:    map[string]error{},
+		// the lookups finish out of order
+		wait: func(string) { time.Sleep(time.Duration(rand.Intn(3000)) * time.Microsecond) },
+	}
+	done := useFakeBackend(b)
+	defer done()
+
+	var input []string
+	var want []batchRecord
+	for n := 0; n < 60; n++ {
+		term := fmt.Sprintf("w%d", n)
+		line := len(input) + 1
+		switch {
+		case n%7 == 3:
+			b.errs[term] = fmt.Errorf("no connection for %s", term)
+			want = append(want, batchRecord{Line: line, Term: term, Error: "no connection for " + term})
+		case n%5 == 1:
+			b.guesses[term] = []string{term + "a", term + "b"}
+			want = append(want, batchRecord{Line: line, Term: term, Suggestions: b.guesses[term]})
+		default:
+			b.words[term] = term + " | | noun a test word"
+			want = append(want, batchRecord{Line: line, Term: term, Headword: term, Definition: "a test word", Source: "fake"})
+		}
+		input = append(input, term)
+		if n%9 == 8 {
+			// the empty lines are skipped but counted
+			input = append(input, "  ")
+		}
+	}
+
+	var got []batchRecord
+	err := runBatch(strings.NewReader(strings.Join(input, "\n")), 8, func(r batchRecord) {
+		got = append(got, r)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("runBatch emitted %d records, want %d", len(got), len(want))
+	}
+	for n := range want {
+		if !reflect.DeepEqual(got[n], want[n]) {
+			t.Errorf("record %d = %+v, want %+v", n, got[n], want[n])
+		}
+	}
+}
+
+func TestRunBatchScanError(t *testing.T) {
+	done := useFakeBackend(&fakeBackend{})
+	defer done()
+	var got []string
+	err := runBatch(&failingReader{"hello\nworld\n"}, 2, func(r batchRecord) {
+		got = append(got, r.Term)
+	})
+	if err == nil || err.Error() != "read failed" {
+		t.Errorf("runBatch returned %v, want the read error", err)
+	}
+	if want := []string{"hello", "world"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("runBatch emitted %q before the error, want %q", got, want)
+	}
+}
+
+// failingReader returns its text and then an error
+type failingReader struct{ text string }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.text == "" {
+		return 0, errors.New("read failed")
+	}
+	n := copy(p, r.text)
+	r.text = r.text[n:]
+	return n, nil
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/nbjahan/go-launchbar"
+)
+
+// entryItems returns the full entry of the word as navigable items: the
+// senses grouped under their part of speech headers, followed by the
+// derivatives and the origin.
+func entryItems(word string) *Items {
+	items := NewItems()
+	e := defineEntry(word)
+	if e == nil {
+		items.Add(newLeaf(word, word, word).SetSubtitle("Open in Dictionary"))
+		return items
+	}
+
+	title := e.Headword
+	if e.Pronunciation != "" {
+		title += " | " + e.Pronunciation + " |"
+	}
+	items.Add(newLeaf(title, e.Headword, e.Headword).
+		SetSubtitle("Open in Dictionary").
+		SetIcon("com.apple.Dictionary").
+		SetQuickLookURL(quickLookURL(e, source())))
+
+	for _, p := range e.Parts {
+		if p.Name != "" {
+			header := NewItem(strings.Title(p.Name)).
+				SetSubtitle(p.Info).
+				SetIcon("DictionaryOff")
+			items.Add(header)
+		}
+		for _, s := range p.Senses {
+			items.Add(senseItem(e.Headword, s))
+		}
+	}
+
+	if groups := synonyms(e.Headword); len(groups) > 0 {
+		// the synonyms are defined when the row is opened
+		items.Add(NewItem("Synonyms").
+			SetSubtitle(synonymSummary(groups)).
+			SetIcon("DictionaryOff").
+			SetAction(conf.ActionDefaultScript).
+			SetActionRunsInBackground(false).
+			SetActionReturnsItems(true).
+			Run("showSynonyms", e.Headword))
+	}
+
+	if len(e.Derivatives) > 0 {
+		children := NewItems()
+		for _, d := range e.Derivatives {
+			children.Add(newLeaf(d, e.Headword, d))
+		}
+		items.Add(NewItem("Derivatives").
+			SetSubtitle(strings.Join(e.Derivatives, ", ")).
+			SetIcon("DictionaryOff").
+			SetChildren(children))
+	}
+
+	if e.Origin != "" {
+		items.Add(originItem(e))
+	}
+
+	if !personal.Has(e.Headword) {
+		items.Add(newActionItem("Add to Personal Words").
+			SetSubtitle("Save “"+e.Headword+"” to your vocabulary").
+			SetIcon("DictionaryOff").
+			Run("addWord", e.Headword))
+	}
+
+	items.Add(exportItem(e))
+	for _, i := range webItems(e.Headword) {
+		items.Add(i)
+	}
+	return items
+}
+
+// originItem returns the etymology of the entry with its language chain as
+// the title and the parts of the origin text as the children.
+func originItem(e *Entry) *Item {
+	title := "Origin"
+	if len(e.OriginChain) > 0 {
+		title += ": " + strings.Join(e.OriginChain, " → ")
+	}
+	children := NewItems()
+	for _, s := range originSentences(e.Origin) {
+		children.Add(newLeaf(s, e.Headword, s))
+	}
+	return newLeaf(title, e.Headword, e.Origin).
+		SetSubtitle(e.Origin).
+		SetIcon("DictionaryOff").
+		SetChildren(children)
+}
+
+// exportItem returns an item whose children paste the entry in each export
+// format and choose the format of ⇧ paste.
+func exportItem(e *Entry) *Item {
+	children := NewItems()
+	current := conf.PasteFormat
+	for _, format := range exportFormats[1:] {
+		children.Add(newActionItem(exportFormatNames[format]).
+			SetSubtitle("Paste as "+exportFormatNames[format]).
+			SetIcon("at.obdev.LaunchBar:CopyActionTemplate").
+			Run("pasteExport", e.Headword, format))
+	}
+	for _, format := range exportFormats {
+		title := "⇧ Paste: " + exportFormatNames[format]
+		if format == current || (current == "" && format == "word") {
+			title += " ✓"
+		}
+		children.Add(newActionItem(title).
+			SetSubtitle("Use this format when pasting with ⇧").
+			SetIcon("DictionaryOff").
+			Run("setPasteFormat", format))
+	}
+	return NewItem("Export").
+		SetSubtitle("Markdown, JSON or plain text").
+		SetIcon("at.obdev.LaunchBar:CopyActionTemplate").
+		SetChildren(children)
+}
+
+func senseItem(word string, s Sense) *Item {
+	title := s.Definition
+	switch {
+	case s.Number > 0:
+		title = fmt.Sprintf("%d. %s", s.Number, s.Definition)
+	case s.Sub:
+		title = "• " + s.Definition
+	}
+	i := newLeaf(title, word, s.Definition)
+	i.SetSubtitle(strings.Join(s.Labels, " "))
+	if len(s.Examples) > 0 {
+		children := NewItems()
+		for _, ex := range s.Examples {
+			children.Add(newLeaf(ex, word, ex))
+		}
+		i.SetChildren(children)
+		if i.Item().Subtitle == "" {
+			i.SetSubtitle(s.Examples[0])
+		}
+	}
+	return i
+}
+
+// newLeaf returns an item that pastes text in the frontmost application when
+// ⇧ is down, otherwise opens the word in Dictionary.app
+func newLeaf(title, word, text string) *Item {
+	return newActionItem(title).
+		SetIcon("at.obdev.LaunchBar:ContentsTemplate").
+		SetModSubtitle("shift", "Paste").
+		Run("pasteOrOpen", word, text)
+}
+
+// newActionItem returns an item that runs the action in background
+func newActionItem(title string) *Item {
+	return NewItem(title).
+		SetAction(conf.ActionDefaultScript).
+		SetActionRunsInBackground(true)
+}
+
+// revealItems returns the definition of the reviewed word followed by the
+// items that grade the recall.
+func revealItems(word string) *Items {
+	items := NewItems()
+	if pw, ok := personal.Get(word); ok && pw.Definition != "" {
+		items.Add(newLeaf(pw.Definition, word, pw.Definition))
+	}
+	if e := defineEntry(word); e != nil {
+		for _, p := range e.Parts {
+			for _, s := range p.Senses {
+				if s.Definition == "" || s.Sub {
+					continue
+				}
+				items.Add(senseItem(e.Headword, s).SetSubtitle(strings.TrimSpace(p.Name + " " + strings.Join(s.Labels, " "))))
+			}
+		}
+	}
+
+	now := time.Now()
+	card := reviewCard{Word: word, Ease: initialEase}
+	if i := deck.index(word); i != -1 {
+		card = deck.Cards[i]
+	}
+	for _, g := range grades {
+		next := card
+		next.Grade(g.Quality, now)
+		subtitle := fmt.Sprintf("Next review in %d days", next.Interval)
+		switch {
+		case g.Quality < 3:
+			subtitle = "Review again in 10 minutes"
+		case next.Interval == 1:
+			subtitle = "Next review tomorrow"
+		}
+		items.Add(newActionItem(g.Name).
+			SetSubtitle(subtitle).
+			SetIcon("DictionaryOff").
+			Run("gradeCard", word, g.Quality))
+	}
+	return items
+}
+
+// synonymWorkers is the number of synonyms looked up at once
+const synonymWorkers = 4
+
+// synonymItems returns the synonyms and antonyms of the groups as result
+// items with their definitions
+func synonymItems(groups []synonymGroup) *Items {
+	type synonym struct{ word, pos, kind string }
+	rows := make([]synonym, 0)
+	words := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(word, pos, kind string) {
+		if seen[kind+word] {
+			return
+		}
+		seen[kind+word] = true
+		rows = append(rows, synonym{word, pos, kind})
+		words = append(words, word)
+	}
+	for _, g := range groups {
+		for _, w := range g.Synonyms {
+			add(w, g.PartOfSpeech, "")
+		}
+	}
+	for _, g := range groups {
+		for _, w := range g.Antonyms {
+			add(w, g.PartOfSpeech, "antonym")
+		}
+	}
+
+	defs := defineSynonyms(words)
+	items := NewItems()
+	for _, r := range rows {
+		subtitle := strings.TrimSpace(r.kind + " " + r.pos)
+		if def := defs[r.word]; def != "" {
+			subtitle += " — " + def
+		}
+		items.Add(wordItem(r.word).SetSubtitle(truncateText(subtitle, 80)))
+	}
+	return items
+}
+
+// defineSynonyms returns the summaries of the definitions of the words. They
+// are cached for a week, the missing ones are looked up on a few workers.
+func defineSynonyms(words []string) map[string]string {
+	defs := make(map[string]string)
+	if _, err := pb.Cache.Get("synonymDefinitions", &defs); err != nil || len(defs) > 1000 {
+		defs = make(map[string]string)
+	}
+	missing := make([]string, 0)
+	for _, w := range words {
+		if _, ok := defs[w]; !ok {
+			defs[w] = ""
+			missing = append(missing, w)
+		}
+	}
+	if len(missing) == 0 {
+		return defs
+	}
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < synonymWorkers && i < len(missing); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for w := range jobs {
+				def := ""
+				if e := defineEntry(w); e != nil {
+					def = e.Summary()
+				}
+				mu.Lock()
+				defs[w] = def
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, w := range missing {
+		jobs <- w
+	}
+	close(jobs)
+	wg.Wait()
+	pb.Cache.Set("synonymDefinitions", defs, 7*24*time.Hour)
+	return defs
+}
+
+func synonymSummary(groups []synonymGroup) string {
+	words := make([]string, 0)
+	for _, g := range groups {
+		words = append(words, g.Synonyms...)
+	}
+	return truncateText(strings.Join(words, ", "), 80)
+}
+
+// wordItem returns an item that browses the entry of the word
+func wordItem(word string) *Item {
+	return NewItem(word).
+		SetIcon("DictionaryOn").
+		SetAction(conf.ActionDefaultScript).
+		SetActionRunsInBackground(false).
+		SetActionReturnsItems(true).
+		Run("showEntry", word)
+}
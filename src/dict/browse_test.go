@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDefineSynonyms(t *testing.T) {
+	var calls, active, most int32
+	b := &fakeBackend{
+		words: map[string]string{},
+		wait: func(string) {
+			atomic.AddInt32(&calls, 1)
+			n := atomic.AddInt32(&active, 1)
+			for m := atomic.LoadInt32(&most); n > m && !atomic.CompareAndSwapInt32(&most, m, n); m = atomic.LoadInt32(&most) {
+			}
+			time.Sleep(2 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+		},
+	}
+	words := make([]string, 0)
+	for n := 0; n < 12; n++ {
+		w := fmt.Sprintf("w%d", n)
+		words = append(words, w)
+		if n != 5 {
+			b.words[w] = w + " | | adjective synonym number " + fmt.Sprint(n)
+		}
+	}
+	lb, done := newTestAction(t, b, `{"backend": "fake"}`)
+	defer done()
+
+	define := func() map[string]string {
+		var defs map[string]string
+		if _, err := lb.Run(func() string {
+			initAction()
+			defs = defineSynonyms(words)
+			return ""
+		}, ""); err != nil {
+			t.Fatal(err)
+		}
+		return defs
+	}
+
+	// every synonym is defined
+	defs := define()
+	for n, w := range words {
+		want := fmt.Sprintf("synonym number %d", n)
+		if n == 5 {
+			want = ""
+		}
+		if defs[w] != want {
+			t.Errorf("the definition of %s is %q, want %q", w, defs[w], want)
+		}
+	}
+	if calls != int32(len(words)) {
+		t.Errorf("%d lookups, want %d", calls, len(words))
+	}
+	if most > synonymWorkers {
+		t.Errorf("%d lookups ran at once, want at most %d", most, synonymWorkers)
+	}
+
+	// and cached, the undefined ones too
+	atomic.StoreInt32(&calls, 0)
+	if defs := define(); defs["w11"] != "synonym number 11" {
+		t.Errorf("the cached definition of w11 is %q", defs["w11"])
+	}
+	if calls != 0 {
+		t.Errorf("%d lookups with the cache, want none", calls)
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	. "github.com/nbjahan/go-launchbar"
+)
+
+// bundleIdentifier is the CFBundleIdentifier of the action
+const bundleIdentifier = "nbjahan.launchbar.livedic"
+
+// commands are the subcommands available when dict is run from the command
+// line instead of LaunchBar.
+var commands = map[string]func(args []string) int{
+	"define":     defineCommand,
+	"spell":      spellCommand,
+	"suggest":    suggestCommand,
+	"batch":      batchCommand,
+	"export":     exportCommand,
+	"flashcards": flashcardsCommand,
+	"tui":        tuiCommand,
+	"serve":      serveCommand,
+	"daemon":     daemonCommand,
+	"lsp":        lspCommand,
+	"config":     configCommand,
+}
+
+// runCommand runs the subcommand in args and returns the exit status. ok is
+// false if args does not start with a subcommand or the binary is run by
+// LaunchBar or another launcher.
+func runCommand(args []string) (status int, ok bool) {
+	if os.Getenv("LB_ACTION_PATH") != "" || os.Getenv("alfred_version") != "" || os.Getenv("LB_OUTPUT") != "" || len(args) == 0 {
+		return 0, false
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return 0, false
+	}
+	return cmd(args[1:]), true
+}
+
+func exportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "markdown", "export format: "+strings.Join(exportFormats, ", "))
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dict export [-format markdown|json|text|word] word...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !isExportFormat(*format) || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if _, err := initCommand("", ""); err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 2
+	}
+
+	status := 0
+	for n, word := range fs.Args() {
+		e := defineEntry(word)
+		if e == nil {
+			fmt.Fprintf(os.Stderr, "dict: no definition for %q\n", word)
+			status = 1
+			continue
+		}
+		out, err := exportEntry(e, *format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+			return 1
+		}
+		if n > 0 && *format != "word" {
+			fmt.Println()
+		}
+		fmt.Println(strings.TrimRight(out, "\n"))
+	}
+	return status
+}
+
+// supportPath returns the action support path that LaunchBar would pass to
+// the action, so the command line shares the config and the user data with
+// it. Other systems use $XDG_CONFIG_HOME/livedic.
+func supportPath() string {
+	if p := os.Getenv("LB_SUPPORT_PATH"); p != "" {
+		return p
+	}
+	if runtime.GOOS != "darwin" {
+		if p := os.Getenv("XDG_CONFIG_HOME"); p != "" {
+			return path.Join(p, "livedic")
+		}
+		return path.Join(os.Getenv("HOME"), ".config", "livedic")
+	}
+	return path.Join(os.Getenv("HOME"), "Library", "Application Support", "LaunchBar", "Action Support", bundleIdentifier)
+}
+
+// cachePath returns the action cache path, $XDG_CACHE_HOME/livedic on other
+// systems.
+func cachePath() string {
+	if p := os.Getenv("LB_CACHE_PATH"); p != "" {
+		return p
+	}
+	if runtime.GOOS != "darwin" {
+		if p := os.Getenv("XDG_CACHE_HOME"); p != "" {
+			return path.Join(p, "livedic")
+		}
+		return path.Join(os.Getenv("HOME"), ".cache", "livedic")
+	}
+	return path.Join(os.Getenv("HOME"), "Library", "Caches", "at.obdev.LaunchBar", "Actions", bundleIdentifier)
+}
+
+func loadUserData(dir string) {
+	personal = loadWordList(dir)
+	history = loadHistory(dir)
+}
+
+// initCommand loads the action's config and user data and selects the
+// backend. backend and lang override the config when they're not empty.
+func initCommand(backend, lang string) (*Config, error) {
+	dir := supportPath()
+	os.MkdirAll(dir, 0755)
+	c := NewConfigDefaults(dir, defaultConfig)
+	reportSettings(loadSettings(c))
+	loadUserData(dir)
+	if backend == "" {
+		backend = conf.Backend
+	}
+	if lang == "" {
+		lang = conf.Lang
+	}
+	return c, useBackend(backend, lang, c)
+}
+
+// lookupOptions are the flags of define, spell and suggest
+type lookupOptions struct {
+	json    bool
+	plain   bool
+	limit   int
+	backend string
+	lang    string
+}
+
+func newLookupFlags(name, usage string) (*flag.FlagSet, *lookupOptions) {
+	o := &lookupOptions{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.BoolVar(&o.json, "json", false, "print JSON")
+	fs.BoolVar(&o.plain, "plain", false, "print tab separated lines")
+	fs.IntVar(&o.limit, "limit", 0, "maximum number of results (default: the limit config)")
+	fs.StringVar(&o.backend, "backend", "", "dictionary backend: "+strings.Join(backendNames(), ", ")+" (default: the backend config)")
+	fs.StringVar(&o.lang, "lang", "", "spelling language, e.g. en_GB (default: the lang config)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: dict %s [--json|--plain] [--limit n] [--backend name] [--lang code] %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs, o
+}
+
+// parse parses the flags before and after the words, so both
+// "dict define --json hello" and "dict define hello --json" work. It returns
+// the words. The limit defaults to limit, or the limit config if it's 0.
+func (o *lookupOptions) parse(fs *flag.FlagSet, args []string, limit int) ([]string, bool) {
+	words := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, false
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		words = append(words, args[0])
+		args = args[1:]
+	}
+	if len(words) == 0 || o.json && o.plain {
+		fs.Usage()
+		return nil, false
+	}
+	if _, err := initCommand(o.backend, o.lang); err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return nil, false
+	}
+	if o.limit <= 0 {
+		o.limit = limit
+	}
+	if o.limit <= 0 {
+		o.limit = conf.Limit
+	}
+	return words, true
+}
+
+func printJSON(v interface{}) {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(b))
+}
+
+// lookupEntries returns the entries lookup() finds for the query
+func lookupEntries(q string, limit int) []*Entry {
+	return lookupEntriesContext(context.Background(), q, limit)
+}
+
+// lookupEntriesContext is lookupEntries that stops when ctx is canceled
+func lookupEntriesContext(ctx context.Context, q string, limit int) []*Entry {
+	entries := make([]*Entry, 0)
+	for _, row := range lookupContext(ctx, q, limit) {
+		e := parseEntry(row[1])
+		if e.Headword == "" {
+			e.Headword = row[0]
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func summary(e *Entry) string {
+	if s := e.Summary(); s != "" {
+		return s
+	}
+	return flatten(e.Raw)
+}
+
+// defineCommand prints the definitions of the words. Only the best match is
+// printed unless --limit is given.
+func defineCommand(args []string) int {
+	fs, o := newLookupFlags("define", "word...")
+	words, ok := o.parse(fs, args, 1)
+	if !ok {
+		return 2
+	}
+
+	status := 0
+	n := 0
+	out := make([]exportedEntry, 0)
+	for _, word := range words {
+		entries := lookupEntries(word, o.limit)
+		if len(entries) == 0 {
+			fmt.Fprintf(os.Stderr, "dict: no definition for %q\n", word)
+			status = 1
+			continue
+		}
+		for _, e := range entries {
+			switch {
+			case o.json:
+				out = append(out, exportedEntry{exportSchemaVersion, source(), e})
+			case o.plain:
+				fmt.Printf("%s\t%s\n", e.Headword, summary(e))
+			default:
+				if n > 0 {
+					fmt.Println()
+				}
+				fmt.Println(strings.TrimRight(exportText(e), "\n"))
+			}
+			n++
+		}
+	}
+	if o.json {
+		printJSON(out)
+	}
+	return status
+}
+
+type spellResult struct {
+	Word        string   `json:"word"`
+	Correct     bool     `json:"correct"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// spellCommand checks the spelling of the words. The words of the personal
+// word list are correct.
+func spellCommand(args []string) int {
+	fs, o := newLookupFlags("spell", "word...")
+	words, ok := o.parse(fs, args, 0)
+	if !ok {
+		return 2
+	}
+
+	status := 0
+	results := make([]spellResult, 0, len(words))
+	for _, word := range words {
+		correct, err := checkSpelling(word)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dict: %s: %v\n", word, err)
+			status = 2
+			continue
+		}
+		r := spellResult{Word: word, Correct: correct, Suggestions: make([]string, 0)}
+		if !r.Correct {
+			if status == 0 {
+				status = 1
+			}
+			guesses := spell(word)
+			rankByHistory(word, guesses)
+			if len(guesses) > o.limit {
+				guesses = guesses[:o.limit]
+			}
+			r.Suggestions = append(r.Suggestions, guesses...)
+		}
+		results = append(results, r)
+	}
+
+	switch {
+	case o.json:
+		printJSON(results)
+	case o.plain:
+		for _, r := range results {
+			fmt.Printf("%s\t%t\t%s\n", r.Word, r.Correct, strings.Join(r.Suggestions, ","))
+		}
+	default:
+		for _, r := range results {
+			if r.Correct {
+				fmt.Printf("%s ✓\n", r.Word)
+			} else if len(r.Suggestions) > 0 {
+				fmt.Printf("%s ✗ did you mean %s?\n", r.Word, strings.Join(r.Suggestions, ", "))
+			} else {
+				fmt.Printf("%s ✗\n", r.Word)
+			}
+		}
+	}
+	return status
+}
+
+type suggestion struct {
+	Word    string `json:"word"`
+	Summary string `json:"summary"`
+}
+
+// suggestCommand prints the words the action would list for the query with
+// their first sense.
+func suggestCommand(args []string) int {
+	fs, o := newLookupFlags("suggest", "query")
+	words, ok := o.parse(fs, args, 0)
+	if !ok {
+		return 2
+	}
+
+	suggestions := make([]suggestion, 0)
+	for _, e := range lookupEntries(strings.Join(words, " "), o.limit) {
+		suggestions = append(suggestions, suggestion{e.Headword, summary(e)})
+	}
+
+	switch {
+	case o.json:
+		printJSON(suggestions)
+	case o.plain:
+		for _, s := range suggestions {
+			fmt.Printf("%s\t%s\n", s.Word, s.Summary)
+		}
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, s := range suggestions {
+			fmt.Fprintf(w, "%s\t%s\n", s.Word, truncateText(s.Summary, textWrapWidth))
+		}
+		w.Flush()
+	}
+	if len(suggestions) == 0 {
+		return 1
+	}
+	return 0
+}
+
+func flashcardsCommand(args []string) int {
+	fs := flag.NewFlagSet("flashcards", flag.ContinueOnError)
+	source := fs.String("source", "words", "the words to export: words (personal word list) or history")
+	format := fs.String("format", "tsv", "output format: tsv or apkg")
+	out := fs.String("o", "", "output file (default: stdout for tsv, ~/Downloads for apkg)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dict flashcards [-source words|history] [-format tsv|apkg] [-o file]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if _, err := initCommand("", ""); err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 2
+	}
+
+	if *format == "tsv" && (*out == "" || *out == "-") {
+		words, err := flashcardWords(*source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+			return 2
+		}
+		if err := writeFlashcardsTSV(os.Stdout, makeFlashcards(words, *source), conf.FlashcardNoteType, conf.FlashcardFields); err != nil {
+			fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if *out == "" {
+		*out = flashcardsPath(*source, *format)
+	}
+	n, err := exportFlashcards(*source, *format, *out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%d cards written to %s\n", n, *out)
+	return 0
+}
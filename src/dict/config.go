@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	. "github.com/nbjahan/go-launchbar"
+)
+
+// settings is the schema of config.json, shared by the action and the
+// command line
+type settings struct {
+	ActionDefaultScript string           `config:"actionDefaultScript" default:"dict" desc:"the script of the items that run the action"`
+	Debug               bool             `config:"debug" desc:"log the input and the output"`
+	Limit               int              `config:"limit" default:"10" min:"1" max:"100" desc:"the number of definitions"`
+	AutoUpdate          bool             `config:"autoUpdate" default:"true" desc:"check for a new version once a day"`
+	PasteFormat         string           `config:"pasteFormat" default:"word" enum:"word,markdown,json,text" desc:"what ⇧+Enter pastes"`
+	Lang                string           `config:"lang" desc:"the spelling language, the system's if empty"`
+	Thesaurus           string           `config:"thesaurus" desc:"a MyThes .dat file, found by lang if empty"`
+	WordOfTheDayList    string           `config:"wordOfTheDayList" desc:"a file with the words of the day, one per line"`
+	WordOfTheDayLevel   string           `config:"wordOfTheDayLevel" desc:"easy, medium or hard, any level if empty"`
+	Backend             string           `config:"backend" desc:"apple or dictd, apple on macOS if empty"`
+	Daemon              bool             `config:"daemon" desc:"keep a warm process to answer the lookups"`
+	DictServer          string           `config:"dictServer" desc:"the host:port of the dictd server"`
+	DictDatabase        string           `config:"dictDatabase" desc:"the dictd database, all of them if empty"`
+	WebDictionaries     []webDictionary  `config:"webDictionaries" desc:"the websites to look up the words the dictionaries don't have"`
+	FlashcardNoteType   string           `config:"flashcardNoteType" default:"Basic" desc:"the Anki note type of the exported text files"`
+	FlashcardFields     []flashcardField `config:"flashcardFields" desc:"the fields of the note type and their templates"`
+}
+
+// conf is the config of the action or the command, decoded by loadSettings
+var conf settings
+
+var defaultSettings = settings{WebDictionaries: defaultWebDictionaries, FlashcardFields: defaultFlashcardFields}
+
+// defaultConfig is shared by the action and the command line
+var defaultConfig = SchemaDefaults(defaultSettings)
+
+// configMigrations upgrade the configs of the older versions, see
+// Config.Migrate. Only append to them.
+var configMigrations = []Migration{
+	// 1.2.0 saved autoupdate, which go-launchbar never read
+	RenameKey("autoupdate", "autoUpdate"),
+}
+
+// loadSettings migrates the config and decodes it to conf. The invalid values
+// are reported and replaced by their defaults.
+func loadSettings(c *Config) error {
+	if err := c.Migrate(configMigrations...); err != nil {
+		return err
+	}
+	conf = defaultSettings
+	return c.Decode(&conf)
+}
+
+func configCommand(args []string) int {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dict config")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	dir := supportPath()
+	os.MkdirAll(dir, 0755)
+	c := NewConfigDefaults(dir, defaultConfig)
+	err := loadSettings(c)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, f := range SchemaFields(defaultSettings) {
+		b, _ := json.Marshal(c.Get(f.Key))
+		value := string(b)
+		if f.Type == "list" || f.Type == "object" {
+			value = fmt.Sprintf("(%s)", f.Type)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.Key, truncateText(value, 30), f.Description)
+	}
+	w.Flush()
+	fmt.Printf("\n%s/config.json\n", dir)
+	if err != nil {
+		reportSettings(err)
+		return 1
+	}
+	return 0
+}
+
+// reportSettings prints the errors of loadSettings for the commands
+func reportSettings(err error) {
+	if verr, ok := err.(ValidationError); ok {
+		for _, e := range verr {
+			fmt.Fprintf(os.Stderr, "dict: %v (using the default)\n", e)
+		}
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The daemon keeps a warm process for the action: LaunchBar starts dict on
+// every keystroke, which then parses Info.plist, the LaunchBar preferences
+// and the thesaurus index, and warms up the dictionary services before it
+// can answer. With the daemon running, dict forwards its arguments and
+// environment over a Unix socket in the cache path and prints the reply.
+// Without it dict runs the action itself, and starts the daemon if the
+// daemon config is true.
+
+const (
+	// daemonIdle is how long the daemon waits for a request before it quits
+	daemonIdle = 10 * time.Minute
+	// daemonDialTimeout is how long the action waits to connect to the
+	// daemon before it runs the action itself
+	daemonDialTimeout = 100 * time.Millisecond
+	// daemonTimeout is the maximum time of a forwarded request
+	daemonTimeout = 10 * time.Second
+	// daemonSocketMax is the longest socket path, sun_path is 104 bytes
+	// with the NUL on macOS
+	daemonSocketMax = 103
+)
+
+type daemonRequest struct {
+	Binary string   `json:"binary"` // the client's executable, see binaryID
+	Args   []string `json:"args"`
+	Env    []string `json:"env"`
+	Dir    string   `json:"dir"`
+}
+
+type daemonReply struct {
+	Out   string `json:"out"`
+	Error string `json:"error,omitempty"`
+}
+
+// daemonSocket returns the path of the daemon's socket. Its folder is only
+// accessible to the user, see listenDaemon. The cache path of a long user
+// name doesn't fit in a socket address, a folder of the user in the temporary
+// folder is used then.
+func daemonSocket() string {
+	dir := os.Getenv("alfred_workflow_cache")
+	if dir == "" {
+		dir = cachePath()
+	}
+	if sock := path.Join(dir, "run", "dict.sock"); len(sock) <= daemonSocketMax {
+		return sock
+	}
+	return path.Join(os.TempDir(), fmt.Sprintf("dict-%d", os.Getuid()), "dict.sock")
+}
+
+// listenDaemon listens on the socket, which is created in a 0700 folder and
+// without access for the group and the others, so no other user can send
+// lookups to the daemon
+func listenDaemon(sock string) (net.Listener, error) {
+	if len(sock) > daemonSocketMax {
+		return nil, fmt.Errorf("the socket path %s is longer than %d bytes", sock, daemonSocketMax)
+	}
+	dir := path.Dir(sock)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// the folder may be in the shared temporary folder, where another user
+	// could have created it first
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); !fi.IsDir() || ok && int(st.Uid) != os.Getuid() {
+		return nil, fmt.Errorf("%s is not a folder of the user", dir)
+	}
+	// MkdirAll keeps the mode of an existing folder
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, err
+	}
+	// a stale socket of a daemon that didn't quit cleanly
+	os.Remove(sock)
+	mask := syscall.Umask(0077)
+	ln, err := net.Listen("unix", sock)
+	syscall.Umask(mask)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(sock, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// binaryID identifies the build of the running executable, so an old daemon
+// doesn't answer for an updated action
+func binaryID() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return exe
+	}
+	return fmt.Sprintf("%s@%d", exe, fi.ModTime().UnixNano())
+}
+
+// isUpdateCall returns true for the update check of go-launchbar, which exits
+// the process it runs in
+func isUpdateCall(args []string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg, `"x-func":"update"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardToDaemon sends the action's input to the daemon and returns its
+// output. ok is false if there's no daemon or it failed, the action should
+// run in-process then.
+func forwardToDaemon() (string, bool) {
+	if isUpdateCall(os.Args[1:]) {
+		return "", false
+	}
+	conn, err := net.DialTimeout("unix", daemonSocket(), daemonDialTimeout)
+	if err != nil {
+		return "", false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(daemonTimeout))
+
+	dir, _ := os.Getwd()
+	req := daemonRequest{binaryID(), os.Args[1:], os.Environ(), dir}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return "", false
+	}
+	var reply daemonReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil || reply.Error != "" {
+		return "", false
+	}
+	return reply.Out, true
+}
+
+// startDaemon starts the daemon in the background if the daemon config is
+// true and it's not running
+func startDaemon() {
+	if pb == nil || !conf.Daemon {
+		return
+	}
+	if conn, err := net.DialTimeout("unix", daemonSocket(), daemonDialTimeout); err == nil {
+		conn.Close()
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		pb.Logger.Println(err)
+		return
+	}
+	cmd := exec.Command(exe, "daemon")
+	// without the launcher's variables, so dict runs the daemon command
+	cmd.Env = make([]string, 0)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "LB_ACTION_PATH=") && !strings.HasPrefix(kv, "alfred_version=") && !strings.HasPrefix(kv, "LB_OUTPUT=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		pb.Logger.Println(err)
+		return
+	}
+	cmd.Process.Release()
+}
+
+func daemonCommand(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	idle := fs.Duration("idle", daemonIdle, "quit after this long without a request")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dict daemon [-idle 10m]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	sock := daemonSocket()
+	if conn, err := net.DialTimeout("unix", sock, daemonDialTimeout); err == nil {
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "dict: a daemon is already listening on %s\n", sock)
+		return 1
+	}
+	ln, err := listenDaemon(sock)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 1
+	}
+	defer os.Remove(sock)
+
+	timer := time.AfterFunc(*idle, func() { ln.Close() })
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		ln.Close()
+	}()
+
+	binary := binaryID()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			// closed when idle or interrupted
+			return 0
+		}
+		timer.Stop()
+		// the action uses the process' environment and globals, so the
+		// requests are answered one at a time
+		quit := serveDaemonRequest(conn, binary)
+		if quit {
+			ln.Close()
+			return 0
+		}
+		timer.Reset(*idle)
+	}
+}
+
+// serveDaemonRequest runs the action for the request on conn. quit is true
+// if the client is a newer build, which then starts its own daemon.
+func serveDaemonRequest(conn net.Conn, binary string) (quit bool) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(daemonTimeout))
+
+	var req daemonRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return false
+	}
+	var reply daemonReply
+	if req.Binary != binary {
+		reply.Error = "the daemon is another build of dict"
+		quit = true
+	} else {
+		reply = runForwarded(req)
+	}
+	json.NewEncoder(conn).Encode(reply)
+	return quit
+}
+
+// runForwarded runs the action with the client's arguments, environment and
+// working directory
+func runForwarded(req daemonRequest) (reply daemonReply) {
+	defer func() {
+		if r := recover(); r != nil {
+			reply = daemonReply{Error: fmt.Sprint(r)}
+		}
+	}()
+	os.Clearenv()
+	for _, kv := range req.Env {
+		if n := strings.Index(kv, "="); n > 0 {
+			os.Setenv(kv[:n], kv[n+1:])
+		}
+	}
+	os.Args = append(os.Args[:1], req.Args...)
+	if req.Dir != "" {
+		os.Chdir(req.Dir)
+	}
+	return daemonReply{Out: runAction()}
+}
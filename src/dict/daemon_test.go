@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListenDaemon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	run := path.Join(dir, "run")
+	sock := path.Join(run, "dict.sock")
+	// an open folder and the stale socket of a daemon that was killed
+	if err := os.Mkdir(run, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(sock, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := listenDaemon(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	for _, f := range []struct {
+		path string
+		mode os.FileMode
+	}{{run, os.ModeDir | 0700}, {sock, os.ModeSocket | 0600}} {
+		fi, err := os.Stat(f.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode() != f.mode {
+			t.Errorf("%s has the mode %v, want %v", f.path, fi.Mode(), f.mode)
+		}
+	}
+
+	// a folder of the socket that's a link
+	link := path.Join(dir, "link")
+	if err := os.Symlink(run, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenDaemon(path.Join(link, "dict.sock")); err == nil || !strings.Contains(err.Error(), "not a folder of the user") {
+		t.Errorf("listenDaemon in a linked folder: %v", err)
+	}
+
+	long := path.Join(dir, strings.Repeat("x", daemonSocketMax), "dict.sock")
+	if _, err := listenDaemon(long); err == nil || !strings.Contains(err.Error(), "longer than") {
+		t.Errorf("listenDaemon of a long path: %v", err)
+	}
+}
+
+func TestDaemonSocket(t *testing.T) {
+	prev, ok := os.LookupEnv("alfred_workflow_cache")
+	defer func() {
+		if ok {
+			os.Setenv("alfred_workflow_cache", prev)
+		} else {
+			os.Unsetenv("alfred_workflow_cache")
+		}
+	}()
+
+	os.Setenv("alfred_workflow_cache", "/Users/me/Library/Caches/livedic")
+	if got, want := daemonSocket(), "/Users/me/Library/Caches/livedic/run/dict.sock"; got != want {
+		t.Errorf("daemonSocket() = %q, want %q", got, want)
+	}
+	os.Setenv("alfred_workflow_cache", "/Users/"+strings.Repeat("long.name", 5)+"/Library/Caches/com.runningwithcrayons.Alfred/Workflow Data/nbjahan.launchbar.livedic")
+	want := path.Join(os.TempDir(), fmt.Sprintf("dict-%d", os.Getuid()), "dict.sock")
+	if got := daemonSocket(); got != want {
+		t.Errorf("daemonSocket() of a long cache path = %q, want %q", got, want)
+	}
+}
+
+// daemonRoundTrip sends the request to the daemon on the socket
+func daemonRoundTrip(sock string, req daemonRequest) (daemonReply, error) {
+	var reply daemonReply
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return reply, err
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return reply, err
+	}
+	err = json.NewDecoder(conn).Decode(&reply)
+	return reply, err
+}
+
+func TestDaemonQuitsForAnotherBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prev, ok := os.LookupEnv("alfred_workflow_cache")
+	os.Setenv("alfred_workflow_cache", dir)
+	defer func() {
+		if ok {
+			os.Setenv("alfred_workflow_cache", prev)
+		} else {
+			os.Unsetenv("alfred_workflow_cache")
+		}
+	}()
+	sock := daemonSocket()
+
+	status := make(chan int, 1)
+	go func() { status <- daemonCommand([]string{"-idle", "10s"}) }()
+	for n := 0; ; n++ {
+		if _, err := os.Stat(sock); err == nil {
+			break
+		}
+		if n == 100 {
+			t.Fatal("the daemon didn't listen")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a request that's not JSON is dropped
+	if conn, err := net.Dial("unix", sock); err == nil {
+		conn.Write([]byte("hello\n"))
+		conn.Close()
+	}
+	reply, err := daemonRoundTrip(sock, daemonRequest{Binary: "/old/dict@1", Args: []string{"hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Error == "" || reply.Out != "" {
+		t.Errorf("another build got %+v, want an error", reply)
+	}
+	select {
+	case s := <-status:
+		if s != 0 {
+			t.Errorf("the daemon exited with %d", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the daemon didn't quit")
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("the socket is left: %v", err)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Entry is a structured dictionary entry parsed from the backend's definition text
+type Entry struct {
+	Headword      string         `json:"headword"`
+	Pronunciation string         `json:"pronunciation,omitempty"`
+	Parts         []PartOfSpeech `json:"parts,omitempty"`
+	Phrases       []string       `json:"phrases,omitempty"`
+	Derivatives   []string       `json:"derivatives,omitempty"`
+	Origin        string         `json:"origin,omitempty"`
+	OriginChain   []string       `json:"originChain,omitempty"` // oldest language first
+	Raw           string         `json:"-"`
+}
+
+// PartOfSpeech groups the senses of an entry, e.g. noun or verb
+type PartOfSpeech struct {
+	Name   string  `json:"name"`
+	Info   string  `json:"info,omitempty"` // grammatical info e.g. (pl. hellos)
+	Senses []Sense `json:"senses,omitempty"`
+}
+
+// Sense is a single meaning of the headword
+type Sense struct {
+	Number     int      `json:"number,omitempty"` // 0 for unnumbered senses and subsenses
+	Sub        bool     `json:"sub,omitempty"`    // a subsense (marked with •)
+	Labels     []string `json:"labels,omitempty"` // e.g. informal, British, [no object]
+	Definition string   `json:"definition"`
+	Examples   []string `json:"examples,omitempty"`
+}
+
+var partsOfSpeech = []string{
+	"plural noun", "modal verb", "auxiliary verb", "phrasal verb", "combining form",
+	"noun", "verb", "adjective", "adverb", "exclamation", "interjection",
+	"pronoun", "preposition", "conjunction", "determiner", "predeterminer",
+	"abbreviation", "prefix", "suffix", "symbol", "contraction", "article", "numeral",
+}
+
+// senseLabels are the labels a sense can start with, the longer ones first so
+// "North American" wins over a shorter prefix
+var senseLabels = []string{
+	"North American", "old-fashioned", "derogatory", "historical", "Australian",
+	"figurative", "technical", "offensive", "informal", "N. Amer.", "literary",
+	"humorous", "Scottish", "British", "archaic", "chiefly", "formal", "vulgar",
+	"mainly", "poetic", "Brit.", "dated", "slang", "rare", "US",
+}
+
+var sectionRegexp = regexp.MustCompile(`\b(PHRASAL VERBS|PHRASES|DERIVATIVES|ORIGIN|USAGE)\b`)
+
+// parseEntry parses the plain text definition returned by the dictionary
+// backend.
+//
+// The text usually looks like:
+//
+//	hel·lo | həˈlō | exclamation 1 used as a greeting: hello there, Katie!
+//	• British used to express surprise ▶ noun (pl. hellos) an utterance of
+//	‘hello’ ORIGIN late 19th century: variant of earlier hollo.
+func parseEntry(text string) *Entry {
+	e := &Entry{Raw: text}
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return e
+	}
+
+	// sections
+	sections := make(map[string]string)
+	locs := sectionRegexp.FindAllStringSubmatchIndex(text, -1)
+	body := text
+	if len(locs) > 0 {
+		body = text[:locs[0][0]]
+		for n, loc := range locs {
+			end := len(text)
+			if n+1 < len(locs) {
+				end = locs[n+1][0]
+			}
+			name := text[loc[2]:loc[3]]
+			sections[name] = strings.TrimSpace(sections[name] + " " + text[loc[1]:end])
+		}
+	}
+	e.Origin = sections["ORIGIN"]
+	if e.Origin == "" {
+		e.Origin = extractEtymology(e.Raw)
+	}
+	e.OriginChain = originChain(e.Origin)
+	e.Derivatives = parseDerivatives(sections["DERIVATIVES"])
+	for _, s := range []string{sections["PHRASES"], sections["PHRASAL VERBS"]} {
+		if s != "" {
+			e.Phrases = append(e.Phrases, s)
+		}
+	}
+
+	// headword and pronunciation
+	if parts := strings.SplitN(body, "|", 3); len(parts) == 3 {
+		e.Headword = strings.TrimSpace(parts[0])
+		e.Pronunciation = strings.TrimSpace(parts[1])
+		body = parts[2]
+	} else {
+		fields := strings.SplitN(body, " ", 2)
+		e.Headword = fields[0]
+		body = ""
+		if len(fields) == 2 {
+			body = fields[1]
+		}
+	}
+	e.Headword = strings.Replace(e.Headword, "·", "", -1)
+
+	e.Parts = parseParts(body)
+	return e
+}
+
+// parseParts splits the body on the parts of speech headers
+func parseParts(text string) []PartOfSpeech {
+	// e.g. (also hallo or hullo) before the first part of speech
+	variants, body := leadingGroup(text)
+	body = strings.Replace(body, "▶", " ▶ ", -1)
+	fields := strings.Fields(body)
+	parts := make([]PartOfSpeech, 0)
+	cur := PartOfSpeech{}
+	buf := make([]string, 0, len(fields))
+	flush := func() {
+		text := strings.Join(buf, " ")
+		if cur.Name != "" || strings.TrimSpace(text) != "" {
+			cur.Info, text = leadingGroup(text)
+			cur.Senses = parseSenses(text)
+			parts = append(parts, cur)
+		}
+		buf = buf[:0]
+	}
+	for n := 0; n < len(fields); n++ {
+		if fields[n] == "▶" {
+			continue
+		}
+		atBoundary := n == 0 || fields[n-1] == "▶" || endsWithAny(fields[n-1], ".!?’”'")
+		if atBoundary {
+			if pos, l := matchPartOfSpeech(fields[n:]); pos != "" {
+				flush()
+				cur = PartOfSpeech{Name: pos}
+				n += l - 1
+				continue
+			}
+		}
+		buf = append(buf, fields[n])
+	}
+	flush()
+	if variants != "" && len(parts) > 0 {
+		parts[0].Info = strings.TrimSpace(variants + " " + parts[0].Info)
+	}
+	return parts
+}
+
+func matchPartOfSpeech(fields []string) (string, int) {
+	for _, pos := range partsOfSpeech {
+		words := strings.Fields(pos)
+		if len(words) > len(fields) {
+			continue
+		}
+		ok := true
+		for i, w := range words {
+			if fields[i] != w {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return pos, len(words)
+		}
+	}
+	return "", 0
+}
+
+// leadingGroup returns a leading (…) group and the rest of the text
+func leadingGroup(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "(") {
+		return "", text
+	}
+	if end := strings.Index(text, ")"); end != -1 {
+		return text[:end+1], strings.TrimSpace(text[end+1:])
+	}
+	return "", text
+}
+
+// parseSenses splits the text on sense numbers (1, 2, …) and subsense bullets.
+// A number starts a sense only at the start of the text or after the end of
+// the previous sense, so "a period of 2 years" is not split.
+func parseSenses(text string) []Sense {
+	senses := make([]Sense, 0)
+	chunks := make([]string, 0)
+	chunk := make([]string, 0)
+	next := 1
+	for _, f := range strings.Fields(text) {
+		if f == strconv.Itoa(next) && (len(chunk) == 0 && len(chunks) == 0 || len(chunk) > 0 && endsSense(chunk[len(chunk)-1])) {
+			chunks = append(chunks, strings.Join(chunk, " "))
+			chunk = chunk[:0]
+			next++
+			continue
+		}
+		chunk = append(chunk, f)
+	}
+	chunks = append(chunks, strings.Join(chunk, " "))
+
+	for n, chunk := range chunks {
+		for k, part := range strings.Split(chunk, "•") {
+			sense := parseSense(part)
+			if sense.Definition == "" && len(sense.Examples) == 0 {
+				continue
+			}
+			if k == 0 {
+				sense.Number = n
+			} else {
+				sense.Sub = true
+			}
+			senses = append(senses, sense)
+		}
+	}
+	return senses
+}
+
+// endsSense reports whether the word can end a sense, i.e. the next word can
+// be a sense number
+func endsSense(word string) bool {
+	return endsWithAny(word, ".;!?’”']")
+}
+
+// endsWithAny reports whether the last character of the word is one of chars
+func endsWithAny(word, chars string) bool {
+	r, size := utf8.DecodeLastRuneInString(word)
+	return size > 0 && strings.ContainsRune(chars, r)
+}
+
+// parseSense parses "[labels] definition: example | example"
+func parseSense(text string) Sense {
+	s := Sense{}
+	text = strings.TrimSpace(text)
+	for text != "" {
+		if text[0] == '[' {
+			if end := strings.Index(text, "]"); end != -1 {
+				s.Labels = append(s.Labels, text[:end+1])
+				text = strings.TrimSpace(text[end+1:])
+				continue
+			}
+		}
+		if label := matchLabel(text); label != "" {
+			s.Labels = append(s.Labels, label)
+			text = strings.TrimSpace(strings.TrimPrefix(text[len(label):], ","))
+			continue
+		}
+		break
+	}
+
+	if pos := strings.Index(text, ": "); pos != -1 {
+		for _, ex := range strings.Split(text[pos+2:], "|") {
+			if ex = strings.TrimSpace(strings.TrimRight(ex, " ;")); ex != "" {
+				s.Examples = append(s.Examples, ex)
+			}
+		}
+		text = text[:pos]
+	}
+	s.Definition = strings.TrimSpace(strings.TrimRight(text, " ;"))
+	return s
+}
+
+func matchLabel(text string) string {
+	for _, label := range senseLabels {
+		if strings.HasPrefix(text, label) && (len(text) == len(label) || strings.IndexAny(text[len(label):len(label)+1], " ,") != -1) {
+			return label
+		}
+	}
+	return ""
+}
+
+// parseDerivatives turns "friendliness noun | …| unfriendly adjective" into
+// ["friendliness (noun)", "unfriendly (adjective)"]
+func parseDerivatives(text string) []string {
+	if text == "" {
+		return nil
+	}
+	// drop the pronunciations
+	parts := strings.Split(text, "|")
+	clean := make([]string, 0, len(parts))
+	for n, p := range parts {
+		if n%2 == 0 {
+			clean = append(clean, p)
+		}
+	}
+	fields := strings.Fields(strings.Join(clean, " "))
+	for n, f := range fields {
+		// adverb. friendliness noun
+		fields[n] = strings.TrimRight(f, ".,;")
+	}
+	out := make([]string, 0)
+	acc := make([]string, 0)
+	for n := 0; n < len(fields); n++ {
+		if pos, l := matchPartOfSpeech(fields[n:]); pos != "" && len(acc) > 0 {
+			out = append(out, strings.Join(acc, " ")+" ("+pos+")")
+			acc = acc[:0]
+			n += l - 1
+			continue
+		}
+		acc = append(acc, fields[n])
+	}
+	if len(acc) > 0 && len(out) == 0 {
+		out = append(out, strings.Join(acc, " "))
+	}
+	return out
+}
+
+// defineEntry looks up the word and returns its parsed entry, nil if the
+// word is not defined.
+func defineEntry(word string) *Entry {
+	headword, def := define(word)
+	if strings.TrimSpace(def) == "" {
+		return nil
+	}
+	e := parseEntry(def)
+	if e.Headword == "" {
+		e.Headword = headword
+	}
+	return e
+}
+
+// Summary returns the first real sense of the entry
+func (e *Entry) Summary() string {
+	for _, p := range e.Parts {
+		for _, s := range p.Senses {
+			if s.Definition != "" {
+				return s.Definition
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// appleHello is the definition of hello in Dictionary.app (New Oxford
+// American Dictionary)
+const appleHello = `hel·lo | heˈlō, həˈlō | (also hallo or hullo) exclamation used as a greeting or to begin a phone conversation: hello there, Katie! • British used to express surprise: hello, what's all this then? • informal expressing sarcasm or anger: Hello! Did you even get what the play was about? noun (pl. hellos) an utterance of “hello”; a greeting: she was getting polite nods and hellos from people. verb (hellos, helloing, helloed) [no object] say or shout “hello”: I pressed the phone button and helloed. ORIGIN late 19th century: variant of earlier hollo; related to holla.`
+
+// appleFriendly has numbered senses and derivatives
+const appleFriendly = `friend·ly | ˈfren(d)lē | adjective (friendlier, friendliest) 1 kind and pleasant: they were friendly to me | a friendly smile. • (of a contest) not seriously or unpleasantly competitive. 2 favorable or serviceable: the house has a friendly atmosphere that lasted 2 years. noun (pl. friendlies) British a game or match that is not part of a serious competition. DERIVATIVES friend·li·ly | ˈfren(d)ləlē | adverb. friend·li·ness | ˈfren(d)lēnəs | noun ORIGIN Old English frēondlīc (see friend, -ly).`
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Entry
+	}{
+		{
+			name: "Dictionary.app",
+			text: appleHello,
+			want: Entry{
+				Headword:      "hello",
+				Pronunciation: "heˈlō, həˈlō",
+				Parts: []PartOfSpeech{
+					{Name: "exclamation", Info: "(also hallo or hullo)", Senses: []Sense{
+						{Definition: "used as a greeting or to begin a phone conversation", Examples: []string{"hello there, Katie!"}},
+						{Sub: true, Labels: []string{"British"}, Definition: "used to express surprise", Examples: []string{"hello, what's all this then?"}},
+						{Sub: true, Labels: []string{"informal"}, Definition: "expressing sarcasm or anger", Examples: []string{"Hello! Did you even get what the play was about?"}},
+					}},
+					{Name: "noun", Info: "(pl. hellos)", Senses: []Sense{
+						{Definition: "an utterance of “hello”; a greeting", Examples: []string{"she was getting polite nods and hellos from people."}},
+					}},
+					{Name: "verb", Info: "(hellos, helloing, helloed)", Senses: []Sense{
+						{Labels: []string{"[no object]"}, Definition: "say or shout “hello”", Examples: []string{"I pressed the phone button and helloed."}},
+					}},
+				},
+				Origin:      "late 19th century: variant of earlier hollo; related to holla.",
+				OriginChain: originChain("late 19th century: variant of earlier hollo; related to holla."),
+			},
+		},
+		{
+			name: "numbered senses",
+			text: appleFriendly,
+			want: Entry{
+				Headword:      "friendly",
+				Pronunciation: "ˈfren(d)lē",
+				Parts: []PartOfSpeech{
+					{Name: "adjective", Info: "(friendlier, friendliest)", Senses: []Sense{
+						{Number: 1, Definition: "kind and pleasant", Examples: []string{"they were friendly to me", "a friendly smile."}},
+						{Sub: true, Definition: "(of a contest) not seriously or unpleasantly competitive."},
+						{Number: 2, Definition: "favorable or serviceable", Examples: []string{"the house has a friendly atmosphere that lasted 2 years."}},
+					}},
+					{Name: "noun", Info: "(pl. friendlies)", Senses: []Sense{
+						{Labels: []string{"British"}, Definition: "a game or match that is not part of a serious competition."},
+					}},
+				},
+				Derivatives: []string{"friend·li·ly (adverb)", "friend·li·ness (noun)"},
+				Origin:      "Old English frēondlīc (see friend, -ly).",
+				OriginChain: originChain("Old English frēondlīc (see friend, -ly)."),
+			},
+		},
+		{
+			name: "WordNet",
+			text: wordnetText("bank", []string{
+				"bank",
+				`    n 1: sloping land (especially the slope beside a body of water);`,
+				`         "they pulled the canoe up on the bank"`,
+				`    2: a financial institution that accepts deposits [syn: {depository`,
+				`       financial institution}, {bank}]`,
+				`    3: a supply held in reserve for 2 or more uses`,
+				`    v 1: tip laterally; "the pilot had to bank the aircraft"`,
+			}),
+			want: Entry{
+				Headword: "bank",
+				Parts: []PartOfSpeech{
+					{Name: "noun", Senses: []Sense{
+						{Number: 1, Definition: "sloping land (especially the slope beside a body of water)", Examples: []string{"they pulled the canoe up on the bank"}},
+						{Number: 2, Definition: "a financial institution that accepts deposits"},
+						{Number: 3, Definition: "a supply held in reserve for 2 or more uses"},
+					}},
+					{Name: "verb", Senses: []Sense{
+						{Number: 1, Definition: "tip laterally", Examples: []string{"the pilot had to bank the aircraft"}},
+					}},
+				},
+			},
+		},
+		{
+			name: "no sections",
+			text: "colour British spelling of color.",
+			want: Entry{
+				Headword: "colour",
+				Parts: []PartOfSpeech{
+					{Senses: []Sense{{Labels: []string{"British"}, Definition: "spelling of color."}}},
+				},
+			},
+		},
+		{
+			name: "empty",
+			text: " \n ",
+			want: Entry{},
+		},
+	}
+	for _, tt := range tests {
+		e := parseEntry(tt.text)
+		e.Raw = ""
+		if !reflect.DeepEqual(*e, tt.want) {
+			t.Errorf("%s: parseEntry =\n%#v\nwant\n%#v", tt.name, *e, tt.want)
+		}
+	}
+}
+
+func TestParseSenses(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Sense
+	}{
+		{"", []Sense{}},
+		{
+			"1 first sense. 2 second sense",
+			[]Sense{{Number: 1, Definition: "first sense."}, {Number: 2, Definition: "second sense"}},
+		},
+		{
+			// numerals in the running text are not sense numbers
+			"1 a period of 2 years: it took 3 days. 2 a unit of 1 year",
+			[]Sense{
+				{Number: 1, Definition: "a period of 2 years", Examples: []string{"it took 3 days."}},
+				{Number: 2, Definition: "a unit of 1 year"},
+			},
+		},
+		{
+			// and only the next number starts a sense
+			"1 one; 3 three; 2 two",
+			[]Sense{{Number: 1, Definition: "one; 3 three"}, {Number: 2, Definition: "two"}},
+		},
+		{
+			// back-to-back numbers
+			"1 2 3",
+			[]Sense{{Number: 1, Definition: "2 3"}},
+		},
+		{
+			// the closing curly quotes end a sense
+			"1 say “hello” 2 greet",
+			[]Sense{{Number: 1, Definition: "say “hello”"}, {Number: 2, Definition: "greet"}},
+		},
+		{
+			"2 years old",
+			[]Sense{{Definition: "2 years old"}},
+		},
+		{
+			"1 [with object] deposit: she banked the money. • [no object] have an account: I bank with Wells Fargo.",
+			[]Sense{
+				{Number: 1, Labels: []string{"[with object]"}, Definition: "deposit", Examples: []string{"she banked the money."}},
+				{Sub: true, Labels: []string{"[no object]"}, Definition: "have an account", Examples: []string{"I bank with Wells Fargo."}},
+			},
+		},
+		{
+			"North American informal, dated a friend",
+			[]Sense{{Labels: []string{"North American", "informal", "dated"}, Definition: "a friend"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := parseSenses(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSenses(%q) =\n%#v\nwant\n%#v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseParts(t *testing.T) {
+	tests := []struct {
+		text string
+		want []PartOfSpeech
+	}{
+		{
+			"noun an utterance of ‘hello’ verb say ‘hello’",
+			[]PartOfSpeech{
+				{Name: "noun", Senses: []Sense{{Definition: "an utterance of ‘hello’"}}},
+				{Name: "verb", Senses: []Sense{{Definition: "say ‘hello’"}}},
+			},
+		},
+		{
+			// a part of speech in the running text is not a header
+			"verb to noun a thing",
+			[]PartOfSpeech{{Name: "verb", Senses: []Sense{{Definition: "to noun a thing"}}}},
+		},
+	}
+	for _, tt := range tests {
+		if got := parseParts(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseParts(%q) =\n%#v\nwant\n%#v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEndsSense(t *testing.T) {
+	tests := []struct {
+		word string
+		want bool
+	}{
+		{"", false},
+		{"word", false},
+		{"word.", true},
+		{"word;", true},
+		{"‘hello’", true},
+		{"“hello”", true},
+		{"(also)", false},
+		{"café", false},
+	}
+	for _, tt := range tests {
+		if got := endsSense(tt.word); got != tt.want {
+			t.Errorf("endsSense(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestParseDerivatives(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"friend·li·ly | ˈfren(d)ləlē | adverb. friend·li·ness | ˈfren(d)lēnəs | noun", []string{"friend·li·ly (adverb)", "friend·li·ness (noun)"}},
+		{"unfriendly adjective", []string{"unfriendly (adjective)"}},
+		{"hellos", []string{"hellos"}},
+	}
+	for _, tt := range tests {
+		if got := parseDerivatives(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDerivatives(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
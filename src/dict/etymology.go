@@ -0,0 +1,110 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// originLanguages are the languages recognized in the origin text
+var originLanguages = []string{
+	"Proto-Indo-European", "Proto-Germanic", "Proto-Slavic", "Proto-Celtic", "Proto-Italic",
+	"Old English", "Middle English", "Early Modern English",
+	"Old French", "Middle French", "Anglo-Norman French", "Anglo-Norman", "Old Northern French",
+	"Old Norse", "Old Saxon", "Old Frisian", "Old High German", "Middle High German",
+	"Middle Low German", "Middle Dutch", "Old Irish", "Old Church Slavonic",
+	"Classical Latin", "Late Latin", "Medieval Latin", "Vulgar Latin", "Ecclesiastical Latin",
+	"Modern Latin", "Latin", "Ancient Greek", "Byzantine Greek", "Late Greek", "Greek",
+	"Biblical Hebrew", "Hebrew", "Aramaic", "Akkadian", "Arabic", "Persian", "Sanskrit",
+	"Hindi", "Urdu", "Tamil", "Malay", "Chinese", "Japanese", "Turkish", "Italian",
+	"Spanish", "Portuguese", "Catalan", "Provençal", "Occitan", "Dutch", "Low German",
+	"German", "Yiddish", "Norse", "Swedish", "Danish", "Norwegian", "Icelandic",
+	"French", "Scots", "Scottish Gaelic", "Irish", "Welsh", "Breton", "Gaulish", "Celtic",
+	"Russian", "Polish", "Czech", "Hungarian", "Finnish", "Afrikaans", "Nahuatl",
+	"Quechua", "Swahili", "Zulu", "Maori", "Hawaiian", "Egyptian", "Coptic",
+}
+
+var originLanguageRegexp = func() *regexp.Regexp {
+	langs := make([]string, len(originLanguages))
+	copy(langs, originLanguages)
+	// longest first so "Late Latin" wins over "Latin"
+	sort.SliceStable(langs, func(i, j int) bool { return len(langs[i]) > len(langs[j]) })
+	for i, l := range langs {
+		langs[i] = regexp.QuoteMeta(l)
+	}
+	return regexp.MustCompile(`\b(` + strings.Join(langs, "|") + `)\b`)
+}()
+
+// originChain returns the languages the word passed through, oldest first,
+// e.g. [Latin, Old French, Middle English].
+//
+// Origin texts usually read backwards in time ("Middle English: from Old
+// French, from Latin"), so the languages are reversed.
+func originChain(origin string) []string {
+	matches := originLanguageRegexp.FindAllString(origin, -1)
+	chain := make([]string, 0, len(matches))
+	seen := make(map[string]bool)
+	for n := len(matches) - 1; n >= 0; n-- {
+		if seen[matches[n]] {
+			continue
+		}
+		seen[matches[n]] = true
+		chain = append(chain, matches[n])
+	}
+	if len(chain) < 2 {
+		return nil
+	}
+	return chain
+}
+
+// etymologyHeadingRegexp matches the etymology section headings of sources
+// like Wiktionary: "Etymology", "Etymology 2", "Origin", "Word origin"
+var etymologyHeadingRegexp = regexp.MustCompile(`(?i)^\s*(etymology( \d+)?|(word )?origin|ORIGIN)\s*:?\s*$`)
+
+// extractEtymology returns the text of the etymology section of a definition
+// whose sections are headings on their own lines. The section ends at the
+// next heading: a short line that doesn't end like a sentence.
+func extractEtymology(text string) string {
+	lines := strings.Split(text, "\n")
+	for n, l := range lines {
+		if !etymologyHeadingRegexp.MatchString(l) {
+			continue
+		}
+		body := make([]string, 0)
+		for _, l := range lines[n+1:] {
+			l = strings.TrimSpace(l)
+			if l == "" {
+				if len(body) > 0 {
+					break
+				}
+				continue
+			}
+			if len(body) > 0 && isHeading(l) {
+				break
+			}
+			body = append(body, l)
+		}
+		return strings.Join(body, " ")
+	}
+	return ""
+}
+
+func isHeading(l string) bool {
+	if len(strings.Fields(l)) > 3 {
+		return false
+	}
+	return !strings.ContainsAny(l[len(l)-1:], ".;:,)")
+}
+
+var originSentenceRegexp = regexp.MustCompile(`[;.]\s+`)
+
+// originSentences splits the origin text into short parts for the child items
+func originSentences(origin string) []string {
+	parts := make([]string, 0)
+	for _, s := range originSentenceRegexp.Split(origin, -1) {
+		if s = strings.TrimSpace(strings.TrimRight(s, ".;")); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// exportFormats are the formats an entry can be exported to. "word" is the
+// bare headword.
+var exportFormats = []string{"word", "markdown", "json", "text"}
+
+var exportFormatNames = map[string]string{
+	"word":     "Word",
+	"markdown": "Markdown",
+	"json":     "JSON",
+	"text":     "Plain Text",
+}
+
+// exportSchemaVersion is the version of the JSON export schema.
+//
+// The JSON export looks like:
+//
+//	{
+//	  "version": 1,
+//	  "source": "Dictionary.app",
+//	  "headword": "hello",
+//	  "pronunciation": "həˈlō",
+//	  "parts": [{
+//	    "name": "exclamation",
+//	    "info": "(also hallo)",
+//	    "senses": [{
+//	      "number": 1,
+//	      "sub": false,
+//	      "labels": ["informal"],
+//	      "definition": "used as a greeting",
+//	      "examples": ["hello there, Katie!"]
+//	    }]
+//	  }],
+//	  "phrases": ["…"],
+//	  "derivatives": ["helloer (noun)"],
+//	  "origin": "late 19th century: variant of earlier hollo"
+//	}
+//
+// Empty fields are omitted.
+const exportSchemaVersion = 1
+
+type exportedEntry struct {
+	Version int    `json:"version"`
+	Source  string `json:"source,omitempty"`
+	*Entry
+}
+
+// textWrapWidth is the line width of the plain text export
+const textWrapWidth = 72
+
+func isExportFormat(format string) bool {
+	_, ok := exportFormatNames[format]
+	return ok
+}
+
+// exportEntry renders the entry in the format
+func exportEntry(e *Entry, format string) (string, error) {
+	switch format {
+	case "word":
+		return e.Headword, nil
+	case "markdown":
+		return exportMarkdown(e), nil
+	case "json":
+		b, err := json.MarshalIndent(exportedEntry{exportSchemaVersion, source(), e}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case "text":
+		return exportText(e), nil
+	}
+	return "", fmt.Errorf("unknown export format: %q", format)
+}
+
+func exportMarkdown(e *Entry) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n", e.Headword)
+	if e.Pronunciation != "" {
+		fmt.Fprintf(&buf, "\n*| %s |*\n", e.Pronunciation)
+	}
+	for _, p := range e.Parts {
+		if p.Name != "" {
+			fmt.Fprintf(&buf, "\n## %s", p.Name)
+			if p.Info != "" {
+				fmt.Fprintf(&buf, " %s", p.Info)
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("\n")
+		n := 0
+		for _, s := range p.Senses {
+			var marker string
+			if !s.Sub {
+				n++
+				marker = fmt.Sprintf("%d. ", n)
+			} else {
+				marker = "   - "
+			}
+			buf.WriteString(marker)
+			if len(s.Labels) > 0 {
+				fmt.Fprintf(&buf, "*%s* ", strings.Join(s.Labels, " "))
+			}
+			buf.WriteString(s.Definition)
+			buf.WriteString("\n")
+			for _, ex := range s.Examples {
+				fmt.Fprintf(&buf, "%s> *%s*\n", strings.Repeat(" ", len(marker)), ex)
+			}
+		}
+	}
+	if len(e.Phrases) > 0 {
+		buf.WriteString("\n## Phrases\n\n")
+		for _, p := range e.Phrases {
+			fmt.Fprintf(&buf, "%s\n", p)
+		}
+	}
+	if len(e.Derivatives) > 0 {
+		fmt.Fprintf(&buf, "\n## Derivatives\n\n%s\n", strings.Join(e.Derivatives, ", "))
+	}
+	if e.Origin != "" {
+		buf.WriteString("\n## Origin\n\n")
+		if len(e.OriginChain) > 0 {
+			fmt.Fprintf(&buf, "*%s*\n\n", strings.Join(e.OriginChain, " → "))
+		}
+		fmt.Fprintf(&buf, "%s\n", e.Origin)
+	}
+	return buf.String()
+}
+
+func exportText(e *Entry) string {
+	var buf bytes.Buffer
+	buf.WriteString(e.Headword)
+	if e.Pronunciation != "" {
+		fmt.Fprintf(&buf, " | %s |", e.Pronunciation)
+	}
+	buf.WriteString("\n")
+	for _, p := range e.Parts {
+		buf.WriteString("\n")
+		if p.Name != "" {
+			buf.WriteString(strings.TrimSpace(p.Name + " " + p.Info))
+			buf.WriteString("\n")
+		}
+		for _, s := range p.Senses {
+			prefix := "  "
+			switch {
+			case s.Number > 0:
+				prefix = fmt.Sprintf("%2d ", s.Number)
+			case s.Sub:
+				prefix = "   • "
+			}
+			text := strings.TrimSpace(strings.Join(s.Labels, " ") + " " + s.Definition)
+			for _, ex := range s.Examples {
+				text += " “" + ex + "”"
+			}
+			buf.WriteString(wrapText(text, textWrapWidth, prefix, strings.Repeat(" ", textWidth(prefix))))
+		}
+	}
+	if len(e.Phrases) > 0 {
+		buf.WriteString("\nPHRASES\n")
+		for _, p := range e.Phrases {
+			buf.WriteString(wrapText(p, textWrapWidth, "", ""))
+		}
+	}
+	if len(e.Derivatives) > 0 {
+		buf.WriteString("\nDERIVATIVES\n")
+		buf.WriteString(wrapText(strings.Join(e.Derivatives, ", "), textWrapWidth, "", ""))
+	}
+	if e.Origin != "" {
+		buf.WriteString("\nORIGIN\n")
+		if len(e.OriginChain) > 0 {
+			buf.WriteString(wrapText(strings.Join(e.OriginChain, " → "), textWrapWidth, "", ""))
+		}
+		buf.WriteString(wrapText(e.Origin, textWrapWidth, "", ""))
+	}
+	return buf.String()
+}
+
+// wrapText wraps the words of s into lines of at most width cells. The first
+// line starts with prefix and the rest with indent.
+func wrapText(s string, width int, prefix, indent string) string {
+	var buf bytes.Buffer
+	line := prefix
+	lineWidth := textWidth(prefix)
+	empty := true
+	for _, word := range strings.Fields(s) {
+		w := textWidth(word)
+		if !empty && lineWidth+1+w > width {
+			buf.WriteString(line + "\n")
+			line, lineWidth, empty = indent, textWidth(indent), true
+		}
+		if !empty {
+			line += " "
+			lineWidth++
+		}
+		line += word
+		lineWidth += w
+		empty = false
+	}
+	buf.WriteString(line + "\n")
+	return buf.String()
+}
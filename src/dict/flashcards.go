@@ -0,0 +1,425 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+// flashcard is a note of the "livedic" Anki note type in the packages, and of
+// the flashcardNoteType config (Basic by default) in the text files
+type flashcard struct {
+	Word          string
+	Pronunciation string
+	PartOfSpeech  string
+	Definition    string
+	Example       string
+	Tags          []string
+}
+
+// flashcardFields are the fields of the note type, in order
+var flashcardFields = []string{"Word", "Pronunciation", "Part of Speech", "Definition", "Example"}
+
+const flashcardNoteType = "livedic"
+const flashcardDeck = "Live Dictionary"
+
+// flashcardField is a field of the note type of the text files. Value is a
+// template with the placeholders {word}, {pronunciation}, {partOfSpeech},
+// {definition}, {example} and {back}, the Back of the Basic note type, which
+// are HTML escaped.
+type flashcardField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// defaultFlashcardFields is the flashcardFields config of a new install, the
+// fields of the Basic note type
+var defaultFlashcardFields = []flashcardField{{"Front", "{word}"}, {"Back", "{back}"}}
+
+func (c flashcard) fields() []string {
+	return []string{c.Word, c.Pronunciation, c.PartOfSpeech, c.Definition, c.Example}
+}
+
+// basicFields returns the Front and the Back of the Basic note type, in HTML.
+// The Back has the pronunciation, the part of speech, the definition and the
+// example on their own lines.
+func (c flashcard) basicFields() []string {
+	head := make([]string, 0, 2)
+	if c.Pronunciation != "" {
+		head = append(head, "| "+html.EscapeString(c.Pronunciation)+" |")
+	}
+	if c.PartOfSpeech != "" {
+		head = append(head, "<i>"+html.EscapeString(c.PartOfSpeech)+"</i>")
+	}
+	back := make([]string, 0, 3)
+	if len(head) > 0 {
+		back = append(back, strings.Join(head, " "))
+	}
+	back = append(back, html.EscapeString(c.Definition))
+	if c.Example != "" {
+		back = append(back, "<i>"+html.EscapeString(c.Example)+"</i>")
+	}
+	return []string{html.EscapeString(c.Word), strings.Join(back, "<br>")}
+}
+
+// expandField fills the template of a field with the card
+func (c flashcard) expandField(template string) (string, error) {
+	var err error
+	out := placeholder.ReplaceAllStringFunc(template, func(m string) string {
+		parts := placeholder.FindStringSubmatch(m)
+		if parts[2] != "" {
+			err = fmt.Errorf("unknown option %q in %s of %q", parts[2], m, template)
+			return m
+		}
+		switch parts[1] {
+		case "word":
+			return html.EscapeString(c.Word)
+		case "pronunciation":
+			return html.EscapeString(c.Pronunciation)
+		case "partOfSpeech":
+			return html.EscapeString(c.PartOfSpeech)
+		case "definition":
+			return html.EscapeString(c.Definition)
+		case "example":
+			return html.EscapeString(c.Example)
+		case "back":
+			return c.basicFields()[1]
+		}
+		err = fmt.Errorf("unknown placeholder %s in %q", m, template)
+		return m
+	})
+	return out, err
+}
+
+// flashcardWords returns the words of the source: "words" for the personal
+// word list or "history" for the lookup history.
+func flashcardWords(source string) ([]string, error) {
+	words := make([]string, 0)
+	switch source {
+	case "words":
+		for _, pw := range personal.Words {
+			words = append(words, pw.Word)
+		}
+	case "history":
+		for _, e := range history.Recent(0) {
+			words = append(words, e.Word)
+		}
+	default:
+		return nil, fmt.Errorf("unknown flashcard source: %q", source)
+	}
+	return words, nil
+}
+
+// makeFlashcards looks up the words and returns their cards. Words without a
+// definition are skipped.
+func makeFlashcards(words []string, tags ...string) []flashcard {
+	cards := make([]flashcard, 0, len(words))
+	for _, word := range words {
+		c := flashcard{Word: word, Tags: append([]string{flashcardNoteType}, tags...)}
+		if e := defineEntry(word); e != nil {
+			c.Word = e.Headword
+			c.Pronunciation = e.Pronunciation
+			c.Definition = e.Summary()
+		outer:
+			for _, p := range e.Parts {
+				for _, s := range p.Senses {
+					if s.Definition == "" {
+						continue
+					}
+					c.PartOfSpeech = p.Name
+					if len(s.Examples) > 0 {
+						c.Example = s.Examples[0]
+					}
+					break outer
+				}
+			}
+		}
+		if pw, ok := personal.Get(word); ok && pw.Definition != "" {
+			c.Definition = pw.Definition
+		}
+		if c.Definition == "" {
+			continue
+		}
+		if c.PartOfSpeech != "" {
+			c.Tags = append(c.Tags, strings.Replace(c.PartOfSpeech, " ", "_", -1))
+		}
+		cards = append(cards, c)
+	}
+	return cards
+}
+
+// writeFlashcardsTSV writes the cards as a tab separated file that Anki
+// imports with File > Import. The header lines tell Anki the note type, the
+// deck, the fields and the tags column. The note type must exist in the
+// collection, unlike the livedic note type of the packages, which is why the
+// default is Basic.
+func writeFlashcardsTSV(w io.Writer, cards []flashcard, noteType string, fields []flashcardField) error {
+	if noteType == "" || len(fields) == 0 {
+		return fmt.Errorf("the flashcards need a note type and its fields")
+	}
+	names := make([]string, len(fields))
+	for n, f := range fields {
+		if f.Name == "" || strings.ContainsAny(f.Name, "\t\n") {
+			return fmt.Errorf("invalid flashcard field name: %q", f.Name)
+		}
+		names[n] = f.Name
+	}
+	var buf bytes.Buffer
+	buf.WriteString("#separator:tab\n")
+	buf.WriteString("#html:true\n")
+	fmt.Fprintf(&buf, "#notetype:%s\n", tsvField(noteType))
+	fmt.Fprintf(&buf, "#deck:%s\n", flashcardDeck)
+	fmt.Fprintf(&buf, "#columns:%s\tTags\n", strings.Join(names, "\t"))
+	fmt.Fprintf(&buf, "#tags column:%d\n", len(fields)+1)
+	for _, c := range cards {
+		values := make([]string, len(fields))
+		for n, f := range fields {
+			v, err := c.expandField(f.Value)
+			if err != nil {
+				return err
+			}
+			values[n] = tsvField(v)
+		}
+		fmt.Fprintf(&buf, "%s\t%s\n", strings.Join(values, "\t"), strings.Join(c.Tags, " "))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func tsvField(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// writeFlashcardsAPKG writes the cards as an Anki package: a zip file with
+// the collection.anki2 SQLite database and an empty media map. The database
+// is created with the sqlite3 command that ships with macOS.
+func writeFlashcardsAPKG(p string, cards []flashcard) error {
+	sqlite, err := sqlitePath()
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "livedic-apkg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	db := path.Join(dir, "collection.anki2")
+	cmd := exec.Command(sqlite, db)
+	cmd.Stdin = strings.NewReader(ankiCollectionSQL(cards, time.Now()))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sqlite3: %v: %s", err, out)
+	}
+	data, err := ioutil.ReadFile(db)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"collection.anki2", data}, {"media", []byte("{}")}} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, buf.Bytes(), 0644)
+}
+
+// ankiCollectionSQL returns the SQL script that creates an Anki 2 (schema 11)
+// collection with the livedic note type, the deck and the cards.
+func ankiCollectionSQL(cards []flashcard, now time.Time) string {
+	ms := now.UnixNano() / int64(time.Millisecond)
+	sec := now.Unix()
+	modelID := int64(1400000000000) // stable, so reimports update the same note type
+	deckID := int64(1400000000001)
+
+	var buf bytes.Buffer
+	buf.WriteString(ankiSchema)
+	buf.WriteString("BEGIN;\n")
+	fmt.Fprintf(&buf, "INSERT INTO col VALUES(1,%d,%d,%d,11,0,0,0,%s,%s,%s,%s,'{}');\n",
+		sec, ms, ms,
+		sqlString(ankiConf(deckID, modelID)),
+		sqlString(ankiModels(modelID, deckID, sec)),
+		sqlString(ankiDecks(deckID, sec)),
+		sqlString(ankiDeckConf()))
+
+	for n, c := range cards {
+		noteID := ms + int64(n)
+		fields := c.fields()
+		sum := sha1.Sum([]byte(fields[0]))
+		csum := binary.BigEndian.Uint32(sum[:4])
+		guid := fmt.Sprintf("livedic-%x", sha1.Sum([]byte(strings.ToLower(c.Word))))[:20]
+		fmt.Fprintf(&buf, "INSERT INTO notes VALUES(%d,%s,%d,%d,-1,%s,%s,%s,%d,0,'');\n",
+			noteID, sqlString(guid), modelID, sec,
+			sqlString(" "+strings.Join(c.Tags, " ")+" "),
+			sqlString(strings.Join(fields, "\x1f")),
+			sqlString(fields[0]), csum)
+		fmt.Fprintf(&buf, "INSERT INTO cards VALUES(%d,%d,%d,0,%d,-1,0,0,%d,0,0,0,0,0,0,0,0,'');\n",
+			noteID, noteID, deckID, sec, n+1)
+	}
+	buf.WriteString("COMMIT;\n")
+	return buf.String()
+}
+
+// sqlitePath returns the sqlite3 command that writes the packages. Linux
+// doesn't always have it.
+func sqlitePath() (string, error) {
+	p, err := exec.LookPath("sqlite3")
+	if err != nil {
+		return "", fmt.Errorf("writing .apkg files needs the sqlite3 command, which is not in PATH")
+	}
+	return p, nil
+}
+
+func sqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func mustJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func ankiConf(deckID, modelID int64) string {
+	return mustJSON(map[string]interface{}{
+		"nextPos": 1, "estTimes": true, "activeDecks": []int64{deckID}, "sortType": "noteFld",
+		"timeLim": 0, "sortBackwards": false, "addToCur": true, "curDeck": deckID,
+		"newBury": true, "newSpread": 0, "dueCounts": true, "curModel": fmt.Sprint(modelID),
+		"collapseTime": 1200,
+	})
+}
+
+func ankiModels(modelID, deckID, mod int64) string {
+	flds := make([]map[string]interface{}, len(flashcardFields))
+	for i, name := range flashcardFields {
+		flds[i] = map[string]interface{}{
+			"name": name, "ord": i, "sticky": false, "rtl": false,
+			"font": "Arial", "size": 20, "media": []string{},
+		}
+	}
+	model := map[string]interface{}{
+		"id": modelID, "name": flashcardNoteType, "type": 0, "mod": mod, "usn": -1,
+		"sortf": 0, "did": deckID, "flds": flds, "tags": []string{}, "vers": []int{},
+		"tmpls": []map[string]interface{}{{
+			"name": "Recall", "ord": 0, "did": nil, "bqfmt": "", "bafmt": "",
+			"qfmt": `<div class="word">{{Word}}</div><div class="pron">{{Pronunciation}}</div>`,
+			"afmt": `{{FrontSide}}<hr id="answer"><div class="pos">{{Part of Speech}}</div>` +
+				`<div>{{Definition}}</div><div class="example">{{Example}}</div>`,
+		}},
+		"css": ".card { font-family: -apple-system, sans-serif; font-size: 20px; text-align: center; }\n" +
+			".word { font-size: 2em; }\n.pron, .pos { color: #888; }\n.example { font-style: italic; margin-top: 1em; }\n",
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+		"req":       [][]interface{}{{0, "any", []int{0}}},
+	}
+	return mustJSON(map[string]interface{}{fmt.Sprint(modelID): model})
+}
+
+func ankiDecks(deckID, mod int64) string {
+	deck := func(id int64, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name, "mod": mod, "usn": -1, "desc": "", "dyn": 0, "conf": 1,
+			"collapsed": false, "extendNew": 10, "extendRev": 50,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	return mustJSON(map[string]interface{}{
+		"1":                deck(1, "Default"),
+		fmt.Sprint(deckID): deck(deckID, flashcardDeck),
+	})
+}
+
+func ankiDeckConf() string {
+	return mustJSON(map[string]interface{}{
+		"1": map[string]interface{}{
+			"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true,
+			"timer": 0, "replayq": true, "dyn": false,
+			"new": map[string]interface{}{
+				"delays": []float64{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500,
+				"separate": true, "order": 1, "perDay": 20, "bury": true,
+			},
+			"rev": map[string]interface{}{
+				"perDay": 100, "ease4": 1.3, "fuzz": 0.05, "minSpace": 1, "ivlFct": 1,
+				"maxIvl": 36500, "bury": true,
+			},
+			"lapse": map[string]interface{}{
+				"delays": []float64{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0,
+			},
+		},
+	})
+}
+
+const ankiSchema = `CREATE TABLE col (id integer primary key, crt integer not null, mod integer not null, scm integer not null, ver integer not null, dty integer not null, usn integer not null, ls integer not null, conf text not null, models text not null, decks text not null, dconf text not null, tags text not null);
+CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null, usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null, flags integer not null, data text not null);
+CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null, mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null, ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null, odue integer not null, odid integer not null, flags integer not null, data text not null);
+CREATE TABLE revlog (id integer primary key, cid integer not null, usn integer not null, ease integer not null, ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null, type integer not null);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+// exportFlashcards writes the cards of the source to p in the format (tsv or
+// apkg) and returns the number of cards.
+func exportFlashcards(source, format, p string) (int, error) {
+	// before looking up the words
+	if format == "apkg" {
+		if _, err := sqlitePath(); err != nil {
+			return 0, err
+		}
+	}
+	words, err := flashcardWords(source)
+	if err != nil {
+		return 0, err
+	}
+	cards := makeFlashcards(words, source)
+	if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+		return 0, err
+	}
+	switch format {
+	case "tsv":
+		var buf bytes.Buffer
+		if err := writeFlashcardsTSV(&buf, cards, conf.FlashcardNoteType, conf.FlashcardFields); err != nil {
+			return 0, err
+		}
+		return len(cards), ioutil.WriteFile(p, buf.Bytes(), 0644)
+	case "apkg":
+		return len(cards), writeFlashcardsAPKG(p, cards)
+	}
+	return 0, fmt.Errorf("unknown flashcard format: %q", format)
+}
+
+// flashcardsPath returns the default path of an exported file in ~/Downloads,
+// which exportFlashcards creates if it's missing, e.g. on a server
+func flashcardsPath(source, format string) string {
+	name := fmt.Sprintf("livedic-%s-%s.%s", source, time.Now().Format("2006-01-02"), format)
+	return path.Join(os.Getenv("HOME"), "Downloads", name)
+}
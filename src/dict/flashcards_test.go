@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+var testCards = []flashcard{
+	{Word: "hello", Pronunciation: "həˈlō", PartOfSpeech: "exclamation", Definition: "used as a greeting", Example: "hello there, Katie!", Tags: []string{"livedic", "words"}},
+	{Word: "R&D", Definition: "research and\tdevelopment", Tags: []string{"livedic"}},
+}
+
+func TestWriteFlashcardsTSV(t *testing.T) {
+	tests := []struct {
+		noteType string
+		fields   []flashcardField
+		want     string
+	}{
+		{"Basic", defaultFlashcardFields, `#separator:tab
+#html:true
+#notetype:Basic
+#deck:Live Dictionary
+#columns:Front	Back	Tags
+#tags column:3
+hello	| həˈlō | <i>exclamation</i><br>used as a greeting<br><i>hello there, Katie!</i>	livedic words
+R&amp;D	research and development	livedic
+`},
+		{"Vocabulary", []flashcardField{
+			{"Word", "{word}"},
+			{"Meaning", "{definition} ({partOfSpeech})"},
+			{"Sentence", "{example}"},
+			{"Sound", ""},
+		}, `#separator:tab
+#html:true
+#notetype:Vocabulary
+#deck:Live Dictionary
+#columns:Word	Meaning	Sentence	Sound	Tags
+#tags column:5
+hello	used as a greeting (exclamation)	hello there, Katie!		livedic words
+R&amp;D	research and development ()			livedic
+`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeFlashcardsTSV(&buf, testCards, tt.noteType, tt.fields); err != nil {
+			t.Errorf("the %s notes: %v", tt.noteType, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("the %s notes are\n%s\nwant\n%s", tt.noteType, got, tt.want)
+		}
+	}
+
+	errors := []struct {
+		noteType string
+		fields   []flashcardField
+	}{
+		{"", defaultFlashcardFields},
+		{"Basic", nil},
+		{"Basic", []flashcardField{{"", "{word}"}}},
+		{"Basic", []flashcardField{{"Front", "{words}"}}},
+		{"Basic", []flashcardField{{"Front", "{word:lower}"}}},
+	}
+	for _, tt := range errors {
+		if err := writeFlashcardsTSV(ioutil.Discard, testCards, tt.noteType, tt.fields); err == nil {
+			t.Errorf("the %q notes with the fields %+v didn't fail", tt.noteType, tt.fields)
+		}
+	}
+}
+
+func TestWriteFlashcardsAPKG(t *testing.T) {
+	sqlite, err := sqlitePath()
+	if err != nil {
+		t.Skip(err)
+	}
+	dir, err := ioutil.TempDir("", "flashcards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := path.Join(dir, "words.apkg")
+	if err := writeFlashcardsAPKG(p, testCards); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = ioutil.ReadAll(r)
+		r.Close()
+	}
+	if len(files) != 2 || string(files["media"]) != "{}" {
+		t.Errorf("the package has the files %d files and the media %q", len(files), files["media"])
+	}
+	db := path.Join(dir, "collection.anki2")
+	if err := ioutil.WriteFile(db, files["collection.anki2"], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []struct{ sql, want string }{
+		{"SELECT ver FROM col;", "11"},
+		{"SELECT replace(flds, char(31), '|'), tags FROM notes ORDER BY id;",
+			"hello|həˈlō|exclamation|used as a greeting|hello there, Katie!\t livedic words \n" +
+				"R&D|||research and\tdevelopment|\t livedic "},
+		{"SELECT count(*) FROM cards JOIN notes ON cards.nid = notes.id;", "2"},
+		{"SELECT json_extract(models, '$.1400000000000.name') FROM col;", flashcardNoteType},
+	}
+	for _, q := range queries {
+		out, err := exec.Command(sqlite, "-separator", "\t", db, q.sql).CombinedOutput()
+		if got := strings.TrimSuffix(string(out), "\n"); err != nil || got != q.want {
+			t.Errorf("%s = %q, %v, want %q", q.sql, got, err, q.want)
+		}
+	}
+}
+
+func TestFlashcardsWithoutSqlite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flashcards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir)
+
+	p := path.Join(dir, "words.apkg")
+	if err := writeFlashcardsAPKG(p, testCards); err == nil || !strings.Contains(err.Error(), "sqlite3") {
+		t.Errorf("writing a package without sqlite3 returned %v", err)
+	}
+	if _, err := exportFlashcards("words", "apkg", p); err == nil || !strings.Contains(err.Error(), "sqlite3") {
+		t.Errorf("exporting a package without sqlite3 returned %v", err)
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("the package was written: %v", err)
+	}
+}
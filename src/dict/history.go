@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxHistory is the number of entries kept in the history file
+const maxHistory = 500
+
+// historyEntry records a committed lookup. The keys are kept short to keep
+// the store compact.
+type historyEntry struct {
+	Word  string `json:"w"`
+	Count int    `json:"n"`
+	Last  int64  `json:"t"` // unix time of the last lookup
+}
+
+// Score ranks the entry by frequency with a decay for older lookups
+func (e historyEntry) Score(now time.Time) float64 {
+	days := now.Sub(time.Unix(e.Last, 0)).Hours() / 24
+	if days < 0 {
+		days = 0
+	}
+	return float64(e.Count) / (1 + days/30)
+}
+
+// lookupHistory is the lookup history stored in the action's support path
+type lookupHistory struct {
+	path    string
+	Entries []historyEntry `json:"h"`
+}
+
+func loadHistory(dir string) *lookupHistory {
+	h := &lookupHistory{path: path.Join(dir, "history.json")}
+	if data, err := ioutil.ReadFile(h.path); err == nil {
+		json.Unmarshal(data, h)
+	}
+	return h
+}
+
+func (h *lookupHistory) index(word string) int {
+	for i, e := range h.Entries {
+		if strings.EqualFold(e.Word, word) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the history entry for word (case insensitive)
+func (h *lookupHistory) Get(word string) (historyEntry, bool) {
+	if h == nil {
+		return historyEntry{}, false
+	}
+	if i := h.index(strings.TrimSpace(word)); i != -1 {
+		return h.Entries[i], true
+	}
+	return historyEntry{}, false
+}
+
+// Score returns the ranking score of the word, 0 if it's never been looked up
+func (h *lookupHistory) Score(word string) float64 {
+	e, ok := h.Get(word)
+	if !ok {
+		return 0
+	}
+	return e.Score(time.Now())
+}
+
+// Record bumps the count and the timestamp of the word
+func (h *lookupHistory) Record(word string) {
+	word = strings.TrimSpace(word)
+	if word == "" {
+		return
+	}
+	now := time.Now()
+	if i := h.index(word); i != -1 {
+		h.Entries[i].Count++
+		h.Entries[i].Last = now.Unix()
+	} else {
+		h.Entries = append(h.Entries, historyEntry{word, 1, now.Unix()})
+	}
+	if len(h.Entries) > maxHistory {
+		entries := h.Frequent(0)
+		h.Entries = entries[:maxHistory]
+	}
+	h.save()
+}
+
+// Remove deletes the word from the history
+func (h *lookupHistory) Remove(word string) {
+	if i := h.index(strings.TrimSpace(word)); i != -1 {
+		h.Entries = append(h.Entries[:i], h.Entries[i+1:]...)
+		h.save()
+	}
+}
+
+// Recent returns the n most recent entries, all of them if n is 0
+func (h *lookupHistory) Recent(n int) []historyEntry {
+	entries := h.sorted(func(a, b historyEntry) bool { return a.Last > b.Last })
+	return limitEntries(entries, n)
+}
+
+// Frequent returns the n highest scored entries, all of them if n is 0
+func (h *lookupHistory) Frequent(n int) []historyEntry {
+	now := time.Now()
+	entries := h.sorted(func(a, b historyEntry) bool { return a.Score(now) > b.Score(now) })
+	return limitEntries(entries, n)
+}
+
+// Search returns the entries containing q, most recent first
+func (h *lookupHistory) Search(q string) []historyEntry {
+	q = strings.ToLower(strings.TrimSpace(q))
+	entries := make([]historyEntry, 0)
+	for _, e := range h.Recent(0) {
+		if strings.Contains(strings.ToLower(e.Word), q) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (h *lookupHistory) sorted(less func(a, b historyEntry) bool) []historyEntry {
+	if h == nil {
+		return nil
+	}
+	entries := make([]historyEntry, len(h.Entries))
+	copy(entries, h.Entries)
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+	return entries
+}
+
+func (h *lookupHistory) save() {
+	if data, err := json.Marshal(h); err == nil {
+		ioutil.WriteFile(h.path, data, 0664)
+	}
+}
+
+func limitEntries(entries []historyEntry, n int) []historyEntry {
+	if n > 0 && len(entries) > n {
+		return entries[:n]
+	}
+	return entries
+}
+
+// rankByHistory moves the previously looked up guesses up among the guesses
+// at the same edit distance from the word. The spell checker's order stays
+// first, the history only breaks its ties.
+func rankByHistory(word string, guesses []string) {
+	if history == nil {
+		return
+	}
+	distances := make([]int, len(guesses))
+	for n, g := range guesses {
+		distances[n] = editDistance(strings.ToLower(word), strings.ToLower(g))
+	}
+	for start := 0; start < len(guesses); {
+		end := start + 1
+		for end < len(guesses) && distances[end] == distances[start] {
+			end++
+		}
+		sortByHistory(guesses[start:end])
+		start = end
+	}
+}
+
+// sortByHistory moves the previously looked up words up, keeping the
+// original order for the ties.
+func sortByHistory(words []string) {
+	if history == nil {
+		return
+	}
+	now := time.Now()
+	scores := make(map[string]float64, len(words))
+	for _, w := range words {
+		if e, ok := history.Get(w); ok {
+			scores[w] = e.Score(now)
+		}
+	}
+	sort.SliceStable(words, func(i, j int) bool { return scores[words[i]] > scores[words[j]] })
+}
+
+// editDistance is the Levenshtein distance of the runes of a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cur[j] = prev[j-1]
+			if ra[i-1] != rb[j-1] {
+				cur[j]++
+			}
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"helo", "hello", 1},
+		{"helo", "halo", 1},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRankByHistory(t *testing.T) {
+	prev := history
+	defer func() { history = prev }()
+	now := time.Now().Unix()
+	history = &lookupHistory{Entries: []historyEntry{
+		{Word: "halo", Count: 5, Last: now},
+		{Word: "hero", Count: 9, Last: now},
+		{Word: "held", Count: 1, Last: now},
+	}}
+
+	tests := []struct {
+		word    string
+		guesses []string
+		want    []string
+	}{
+		// halo beats hello at the same distance, but hero stays behind the
+		// farther hole the spell checker put first
+		{"helo", []string{"hello", "help", "halo", "hole", "hero"}, []string{"halo", "hello", "help", "hole", "hero"}},
+		{"helo", []string{"helots", "halo", "held"}, []string{"helots", "halo", "held"}},
+		{"HELO", []string{"help", "held"}, []string{"held", "help"}},
+		{"helo", []string{}, []string{}},
+	}
+	for _, tt := range tests {
+		got := append([]string(nil), tt.guesses...)
+		if got == nil {
+			got = []string{}
+		}
+		rankByHistory(tt.word, got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rankByHistory(%q, %q) = %q, want %q", tt.word, tt.guesses, got, tt.want)
+		}
+	}
+}
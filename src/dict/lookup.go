@@ -1,103 +1,40 @@
 package main
 
-/*
-#cgo CFLAGS: -x objective-c
-#cgo LDFLAGS: -framework Cocoa -framework AppKit
-#import <Foundation/Foundation.h>
-#import <AppKit/NSSpellChecker.h>
-
-const char*
-define(const char *s, int *start, int *len) {
-  NSString *word = [[NSString alloc] initWithCString:s encoding:NSUTF8StringEncoding];
-  CFRange termRange = DCSGetTermRangeInString(NULL, (CFStringRef)word, 0);
-
-  NSString *definition = @"";
-  *start = termRange.location;
-  *len = termRange.length;
-
-  if(*start != -1) {
-    definition = (NSString*)DCSCopyTextDefinition(NULL, (CFStringRef)word, termRange);
-    NSString *first_part = [word substringToIndex: *start];
-    NSString *second_part = [word substringWithRange: NSMakeRange(*start, *len)];
-    *start = [first_part lengthOfBytesUsingEncoding: NSUTF8StringEncoding];
-    *len = [second_part lengthOfBytesUsingEncoding: NSUTF8StringEncoding];
-  }
-
-  return [definition UTF8String];
-}
-
-const char **
-spell(const char * s, int *n) {
-  NSString * term = [[NSString alloc] initWithCString:s encoding:NSUTF8StringEncoding];
-  NSSpellChecker *spellChecker = [NSSpellChecker sharedSpellChecker];
-  NSArray *guesses = [spellChecker guessesForWordRange:NSMakeRange(0, [term length])
-                                                  inString:term
-                                                  language:[spellChecker language]
-                                    inSpellDocumentWithTag:0];
-  int count = [guesses count];
-  *n = count;
-  const char** cArray = malloc(sizeof(const char *) * count);
-  for (int i=0; i<count; ++i) {
-    cArray[i] = [[guesses objectAtIndex:i] UTF8String];
-  }
-  return cArray;
-}
-*/
-import "C"
-
 import (
+	"context"
 	"strings"
-	"unsafe"
 )
 
-func define(word string) (string, string) {
-	cs := C.CString(word)
-	defer C.free(unsafe.Pointer(cs))
-	var start, l C.int
-	cdef := C.define(cs, &start, &l)
-	if start != -1 {
-		word = word[start : start+l]
-	}
-	return word, C.GoString(cdef)
+func lookup(q string, limit int) [][]string {
+	return lookupContext(context.Background(), q, limit)
 }
 
-func spell(word string) []string {
-	cs := C.CString(word)
-	defer C.free(unsafe.Pointer(cs))
-	var n C.int
-	r := C.spell(cs, &n)
-	ar := ((*[1 << 30]*C.char)(unsafe.Pointer(r)))[:n]
-	defer C.free(unsafe.Pointer(r))
-	guesses := make([]string, 0)
-	for _, s := range ar {
-		guesses = append(guesses, C.GoString(s))
-	}
-	return guesses
-}
-func lookup(q string, limit int) [][]string {
+// lookupContext is lookup that stops between the backend calls when ctx is
+// canceled, e.g. when the query changes while typing. It returns nil then.
+func lookupContext(ctx context.Context, q string, limit int) [][]string {
 	q = strings.TrimSpace(q)
 	if q == "" {
 		return nil
 	}
 
-	words := spell(q)
+	var words []string
+	if !personal.Has(q) {
+		words = spell(q)
+		rankByHistory(q, words)
+	}
 	words = append(words, q)
 	definitions := make([][]string, 0)
 	for _, word := range words {
 		if limit == 0 {
 			break
 		}
+		if ctx.Err() != nil {
+			return nil
+		}
 		subword, def := define(word)
 		if def == "" {
 			continue
 		}
-		def = strings.Replace(def, "\n", " ", -1)
-		def = strings.TrimSpace(def)
-		fields := strings.Fields(def)
-		if len(fields) > 0 {
-			fields = fields[1:]
-		}
-		def = strings.Join(fields, " ")
 		def = strings.TrimSpace(def)
 		if def == "" {
 			continue
@@ -134,3 +71,16 @@ func lookup(q string, limit int) [][]string {
 
 	return out[0 : len(out)-1]
 }
+
+// defineEntryContext is defineEntry that doesn't look up the word when ctx is
+// canceled, and returns nil if ctx was canceled during the lookup
+func defineEntryContext(ctx context.Context, word string) *Entry {
+	if ctx.Err() != nil {
+		return nil
+	}
+	e := defineEntry(word)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return e
+}
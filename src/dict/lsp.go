@@ -0,0 +1,674 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// lspAddWordCommand is the command of the "add to personal words" code action
+const lspAddWordCommand = "dict.addWord"
+
+// lspServer is a Language Server Protocol server over stdio. It checks the
+// spelling of the comments and strings of source files and of prose files
+// (Markdown, plain text, …), hovers the definition of a word and offers the
+// spelling guesses and adding the word to the personal words as code
+// actions.
+type lspServer struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*lspDocument
+	spelled  map[string]bool // the spell checks, cleared when a word is added
+	shutdown bool
+}
+
+type lspDocument struct {
+	uri        string
+	languageID string
+	text       string
+	lines      []int // the offsets of the line starts
+}
+
+type lspRequest struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// the JSON-RPC error codes
+const (
+	lspParseError     = -32700
+	lspInvalidParams  = -32602
+	lspMethodNotFound = -32601
+	lspInvalidRequest = -32600
+	lspInternalError  = -32603
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"` // in UTF-16 code units
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspCodeCommand struct {
+	Title     string        `json:"title"`
+	Command   string        `json:"command"`
+	Arguments []interface{} `json:"arguments,omitempty"`
+}
+
+type lspCodeAction struct {
+	Title       string          `json:"title"`
+	Kind        string          `json:"kind"`
+	Diagnostics []lspDiagnostic `json:"diagnostics,omitempty"`
+	IsPreferred bool            `json:"isPreferred,omitempty"`
+	Edit        interface{}     `json:"edit,omitempty"`
+	Command     *lspCodeCommand `json:"command,omitempty"`
+}
+
+type lspTextDocument struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Text       string `json:"text"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+}
+
+func lspCommand(args []string) int {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	backend := fs.String("backend", "", "dictionary backend: "+strings.Join(backendNames(), ", ")+" (default: the backend config)")
+	lang := fs.String("lang", "", "spelling language, e.g. en_GB (default: the lang config)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dict lsp [-backend name] [-lang code]")
+		fmt.Fprintln(os.Stderr, "\nSpeaks the Language Server Protocol on stdin and stdout.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if _, err := initCommand(*backend, *lang); err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 2
+	}
+	s := &lspServer{
+		in:      bufio.NewReader(os.Stdin),
+		out:     os.Stdout,
+		docs:    make(map[string]*lspDocument),
+		spelled: make(map[string]bool),
+	}
+	return s.run()
+}
+
+// run reads the messages until exit and returns the exit status: 0 if the
+// client asked for a shutdown first
+func (s *lspServer) run() int {
+	for {
+		data, err := s.read()
+		if err == io.EOF {
+			return 1
+		}
+		if err != nil {
+			logError(err)
+			return 1
+		}
+		var req lspRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			s.replyError(nil, lspParseError, err.Error())
+			continue
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		result, rerr := s.handle(req)
+		if req.ID == nil {
+			// a notification
+			continue
+		}
+		if rerr != nil {
+			s.replyError(req.ID, rerr.Code, rerr.Message)
+		} else {
+			s.write(lspResponse{"2.0", req.ID, result})
+		}
+	}
+}
+
+// read returns the content of the next message
+func (s *lspServer) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if n := strings.Index(line, ":"); n != -1 && strings.EqualFold(line[:n], "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(line[n+1:])); err != nil {
+				return nil, fmt.Errorf("lsp: bad header %q", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("lsp: missing Content-Length")
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(s.in, data)
+	return data, err
+}
+
+func (s *lspServer) write(v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		logError(err)
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+func (s *lspServer) replyError(id *json.RawMessage, code int, message string) {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	s.write(lspErrorResponse{"2.0", id, lspError{code, message}})
+}
+
+func (s *lspServer) notify(method string, params interface{}) {
+	s.write(lspNotification{"2.0", method, params})
+}
+
+func (s *lspServer) handle(req lspRequest) (interface{}, *lspError) {
+	if s.shutdown && req.Method != "exit" {
+		return nil, &lspError{lspInvalidRequest, "the server is shut down"}
+	}
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1, // full
+				"hoverProvider":          true,
+				"codeActionProvider":     true,
+				"executeCommandProvider": map[string]interface{}{"commands": []string{lspAddWordCommand}},
+			},
+			"serverInfo": map[string]string{"name": "dict"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		s.open(p.TextDocument.URI, p.TextDocument.LanguageID, p.TextDocument.Text)
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument   lspTextDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		if d, ok := s.docs[p.TextDocument.URI]; ok && len(p.ContentChanges) > 0 {
+			s.open(d.uri, d.languageID, p.ContentChanges[len(p.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var p lspTextDocumentPosition
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		delete(s.docs, p.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": p.TextDocument.URI, "diagnostics": []lspDiagnostic{}})
+	case "textDocument/hover":
+		var p lspTextDocumentPosition
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		return s.hover(p), nil
+	case "textDocument/codeAction":
+		var p struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+			Context      struct {
+				Diagnostics []lspDiagnostic `json:"diagnostics"`
+			} `json:"context"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		return s.codeActions(p.TextDocument.URI, p.Context.Diagnostics), nil
+	case "workspace/executeCommand":
+		var p lspCodeCommand
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		if p.Command != lspAddWordCommand || len(p.Arguments) != 1 {
+			return nil, &lspError{lspInvalidParams, "unknown command " + p.Command}
+		}
+		word, _ := p.Arguments[0].(string)
+		if err := personal.Add(word, ""); err != nil {
+			logError(err)
+			return nil, &lspError{lspInternalError, err.Error()}
+		}
+		s.spelled = make(map[string]bool)
+		for _, d := range s.docs {
+			s.publish(d)
+		}
+	case "initialized", "$/cancelRequest", "$/setTrace", "textDocument/didSave", "workspace/didChangeConfiguration":
+	default:
+		if req.ID != nil {
+			return nil, &lspError{lspMethodNotFound, "unsupported method " + req.Method}
+		}
+	}
+	return nil, nil
+}
+
+func (s *lspServer) open(uri, languageID, text string) {
+	d := &lspDocument{uri: uri, languageID: languageID, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	s.docs[uri] = d
+	s.publish(d)
+}
+
+// publish sends the misspelled words of the document
+func (s *lspServer) publish(d *lspDocument) {
+	diagnostics := make([]lspDiagnostic, 0)
+	for _, span := range spellableWords(d.languageID, d.uri, d.text) {
+		word := d.text[span[0]:span[1]]
+		ok, err := s.check(word)
+		if err != nil {
+			// the backend is down, the words are not checked until it's back
+			logError(err)
+			break
+		}
+		if ok {
+			continue
+		}
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    lspRange{d.position(span[0]), d.position(span[1])},
+			Severity: 3, // information
+			Code:     "misspelled",
+			Source:   "dict",
+			Message:  fmt.Sprintf("“%s” is misspelled", word),
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": d.uri, "diagnostics": diagnostics})
+}
+
+// check returns true if the word is spelled correctly. The failed checks are
+// not cached.
+func (s *lspServer) check(word string) (bool, error) {
+	if ok, found := s.spelled[word]; found {
+		return ok, nil
+	}
+	ok, err := checkSpelling(word)
+	if err != nil {
+		return false, err
+	}
+	s.spelled[word] = ok
+	return ok, nil
+}
+
+// hover returns the definition of the word at the position in Markdown, or
+// the spelling guesses if it's not defined
+func (s *lspServer) hover(p lspTextDocumentPosition) interface{} {
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	start, end := d.wordAt(d.offset(p.Position))
+	if start == end {
+		return nil
+	}
+	word := d.text[start:end]
+	var value string
+	if e := defineEntry(word); e != nil {
+		value = exportMarkdown(e)
+		if src := source(); src != "" {
+			value += "\n\n*" + src + "*"
+		}
+	} else if pw, ok := personal.Get(word); ok {
+		value = fmt.Sprintf("**%s**\n\n%s", pw.Word, pw.Definition)
+	} else if guesses := spell(word); len(guesses) > 0 {
+		value = fmt.Sprintf("No definition for **%s**. Did you mean %s?", word, strings.Join(guesses, ", "))
+	} else {
+		return nil
+	}
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": value},
+		"range":    lspRange{d.position(start), d.position(end)},
+	}
+}
+
+// codeActions returns the spelling guesses and "Add to Personal Words" for
+// the misspelled words of the diagnostics
+func (s *lspServer) codeActions(uri string, diagnostics []lspDiagnostic) []lspCodeAction {
+	actions := make([]lspCodeAction, 0)
+	d, ok := s.docs[uri]
+	if !ok {
+		return actions
+	}
+	for _, diag := range diagnostics {
+		if diag.Source != "dict" || diag.Code != "misspelled" {
+			continue
+		}
+		word := d.text[d.offset(diag.Range.Start):d.offset(diag.Range.End)]
+		guesses := spell(word)
+		rankByHistory(word, guesses)
+		if len(guesses) > 5 {
+			guesses = guesses[:5]
+		}
+		for n, guess := range guesses {
+			actions = append(actions, lspCodeAction{
+				Title:       fmt.Sprintf("Change to “%s”", guess),
+				Kind:        "quickfix",
+				Diagnostics: []lspDiagnostic{diag},
+				IsPreferred: n == 0,
+				Edit: map[string]interface{}{
+					"changes": map[string][]lspTextEdit{uri: {{diag.Range, matchCase(guess, word)}}},
+				},
+			})
+		}
+		actions = append(actions, lspCodeAction{
+			Title:       fmt.Sprintf("Add “%s” to Personal Words", word),
+			Kind:        "quickfix",
+			Diagnostics: []lspDiagnostic{diag},
+			Command:     &lspCodeCommand{"Add to Personal Words", lspAddWordCommand, []interface{}{word}},
+		})
+	}
+	return actions
+}
+
+// matchCase capitalizes the guess like the word, e.g. Recieve → Receive
+func matchCase(guess, word string) string {
+	r, _ := utf8.DecodeRuneInString(word)
+	g, size := utf8.DecodeRuneInString(guess)
+	if unicode.IsUpper(r) && !unicode.IsUpper(g) {
+		return string(unicode.ToUpper(g)) + guess[size:]
+	}
+	return guess
+}
+
+// position converts the byte offset to a line and UTF-16 character
+func (d *lspDocument) position(offset int) lspPosition {
+	line := len(d.lines) - 1
+	for line > 0 && d.lines[line] > offset {
+		line--
+	}
+	return lspPosition{line, len(utf16.Encode([]rune(d.text[d.lines[line]:offset])))}
+}
+
+// offset converts the position to a byte offset
+func (d *lspDocument) offset(p lspPosition) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[p.Line]
+	for n := 0; n < p.Character && offset < len(d.text) && d.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		n += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
+
+// wordAt returns the word around the offset
+func (d *lspDocument) wordAt(offset int) (int, int) {
+	isLetter := func(r rune) bool { return unicode.IsLetter(r) || r == '\'' || r == '’' }
+	start, end := offset, offset
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(d.text[:start])
+		if !isLetter(r) {
+			break
+		}
+		start -= size
+	}
+	for end < len(d.text) {
+		r, size := utf8.DecodeRuneInString(d.text[end:])
+		if !isLetter(r) {
+			break
+		}
+		end += size
+	}
+	return start, end
+}
+
+// lspCommentSyntax is the comment syntax of the source files
+type lspCommentSyntax struct {
+	line       []string
+	blockStart string
+	blockEnd   string
+}
+
+var (
+	cComments    = lspCommentSyntax{[]string{"//"}, "/*", "*/"}
+	hashComments = lspCommentSyntax{[]string{"#"}, "", ""}
+	dashComments = lspCommentSyntax{[]string{"--"}, "", ""}
+)
+
+// lspLanguages are the comment syntaxes by language id, the other languages
+// use C comments
+var lspLanguages = map[string]lspCommentSyntax{
+	"python": hashComments, "shellscript": hashComments, "ruby": hashComments,
+	"perl": hashComments, "yaml": hashComments, "toml": hashComments,
+	"r": hashComments, "makefile": hashComments, "dockerfile": hashComments,
+	"elixir": hashComments, "lua": dashComments, "sql": dashComments,
+	"haskell": dashComments,
+}
+
+// lspProse are the language ids and extensions that are checked entirely
+var lspProse = map[string]bool{
+	"markdown": true, "plaintext": true, "text": true, "restructuredtext": true,
+	"asciidoc": true, "git-commit": true, "latex": true,
+	".md": true, ".markdown": true, ".txt": true, ".rst": true, ".adoc": true, ".tex": true,
+}
+
+var (
+	lspWordRegexp = regexp.MustCompile(`\p{L}+(?:['’]\p{L}+)*`)
+	// the Markdown code blocks, inline code, link targets, URLs and tags
+	lspMarkdownSkip = regexp.MustCompile("(?ms)^ *(```|~~~).*?^ *(```|~~~)|`[^`\n]*`|\\]\\([^)]*\\)|\\w+://\\S+|<[^>\n]+>")
+	lspURLRegexp    = regexp.MustCompile(`\w+://\S+`)
+)
+
+// spellableWords returns the byte ranges of the words to check: the words
+// of prose files, and the words of the comments and strings of the others.
+// Identifiers (camelCase, snake_case, with digits) and words shorter than 3
+// letters are skipped.
+func spellableWords(languageID, uri, text string) [][]int {
+	var masked []byte
+	if lspProse[languageID] || (languageID == "" && lspProse[path.Ext(uri)]) {
+		masked = blank([]byte(text), lspMarkdownSkip)
+	} else {
+		syntax, ok := lspLanguages[languageID]
+		if !ok {
+			syntax = cComments
+		}
+		masked = blank(maskCode(text, syntax), lspURLRegexp)
+	}
+
+	words := make([][]int, 0)
+	for _, span := range lspWordRegexp.FindAllIndex(masked, -1) {
+		word := text[span[0]:span[1]]
+		if utf8.RuneCountInString(word) < 3 || isIdentifier(text, span[0], span[1]) {
+			continue
+		}
+		words = append(words, span)
+	}
+	return words
+}
+
+// blank replaces the matches of re with spaces, keeping the offsets
+func blank(text []byte, re *regexp.Regexp) []byte {
+	for _, span := range re.FindAllIndex(text, -1) {
+		for i := span[0]; i < span[1]; i++ {
+			if text[i] != '\n' {
+				text[i] = ' '
+			}
+		}
+	}
+	return text
+}
+
+// maskCode returns the text with everything but the comments and the string
+// literals replaced by spaces
+func maskCode(text string, syntax lspCommentSyntax) []byte {
+	masked := []byte(text)
+	const (
+		code = iota
+		lineComment
+		blockComment
+		str
+	)
+	state := code
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch state {
+		case code:
+			masked[i] = ' '
+			switch {
+			case syntax.blockStart != "" && strings.HasPrefix(text[i:], syntax.blockStart):
+				state = blockComment
+				for j := 1; j < len(syntax.blockStart); j++ {
+					masked[i+j] = ' '
+				}
+				i += len(syntax.blockStart) - 1
+			case c == '"' || c == '\'' || c == '`':
+				state, quote = str, c
+			default:
+				for _, marker := range syntax.line {
+					if strings.HasPrefix(text[i:], marker) {
+						state = lineComment
+						break
+					}
+				}
+			}
+			if c == '\n' {
+				masked[i] = '\n'
+			}
+		case lineComment:
+			if c == '\n' {
+				state = code
+			}
+		case blockComment:
+			if strings.HasPrefix(text[i:], syntax.blockEnd) {
+				state = code
+				for j := 0; j < len(syntax.blockEnd); j++ {
+					masked[i+j] = ' '
+				}
+				i += len(syntax.blockEnd) - 1
+			}
+		case str:
+			switch {
+			case c == '\\' && quote != '`':
+				// keep the escape out of the words, e.g. \n
+				masked[i] = ' '
+				if i+1 < len(text) && text[i+1] != '\n' {
+					masked[i+1] = ' '
+					i++
+				}
+			case c == quote:
+				masked[i] = ' '
+				state = code
+			case c == '\n' && quote != '`':
+				state = code
+			}
+		}
+	}
+	return masked
+}
+
+// isIdentifier returns true if the word at text[start:end] is a part of an
+// identifier, a path or a file name rather than a word
+func isIdentifier(text string, start, end int) bool {
+	word := text[start:end]
+	for i, r := range word {
+		if i > 0 && unicode.IsUpper(r) {
+			return true
+		}
+	}
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(text[:start]); strings.ContainsRune("_$@#%/\\.", r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	if end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if strings.ContainsRune("_/(", r) || unicode.IsDigit(r) {
+			return true
+		}
+		if r == '.' && end+size < len(text) {
+			if next, _ := utf8.DecodeRuneInString(text[end+size:]); unicode.IsLetter(next) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lspMessage frames the message like the clients do
+func lspMessage(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+func newTestLSPServer(in string) (*lspServer, *bytes.Buffer) {
+	var out bytes.Buffer
+	return &lspServer{
+		in:      bufio.NewReader(strings.NewReader(in)),
+		out:     &out,
+		docs:    make(map[string]*lspDocument),
+		spelled: make(map[string]bool),
+	}, &out
+}
+
+// lspReplies reads the messages the server wrote
+func lspReplies(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	r, _ := newTestLSPServer(out.String())
+	replies := make([]map[string]interface{}, 0)
+	for {
+		data, err := r.read()
+		if err != nil {
+			return replies
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("%v: %s", err, data)
+		}
+		replies = append(replies, m)
+	}
+}
+
+func TestLSPFraming(t *testing.T) {
+	done := useFakeBackend(&fakeBackend{})
+	defer done()
+
+	body := `{"jsonrpc":"2.0","id":2,"method":"hover","params":{}}`
+	in := lspMessage(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": map[string]interface{}{}}) +
+		// other headers and the header name in any case
+		fmt.Sprintf("content-length: %d\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n%s", len(body), body) +
+		lspMessage(map[string]interface{}{"jsonrpc": "2.0", "method": "initialized", "params": map[string]interface{}{}}) +
+		"Content-Length: 5\r\n\r\n{bad}" +
+		lspMessage(map[string]interface{}{"jsonrpc": "2.0", "id": "s", "method": "shutdown"}) +
+		lspMessage(map[string]interface{}{"jsonrpc": "2.0", "method": "exit"})
+	s, out := newTestLSPServer(in)
+	if status := s.run(); status != 0 {
+		t.Errorf("run() = %d after shutdown and exit, want 0", status)
+	}
+
+	replies := lspReplies(t, out)
+	if len(replies) != 4 {
+		t.Fatalf("%d replies, want 4: %s", len(replies), out)
+	}
+	if replies[0]["id"] != 1.0 || replies[0]["result"].(map[string]interface{})["capabilities"] == nil {
+		t.Errorf("initialize replied %v", replies[0])
+	}
+	if err, _ := replies[1]["error"].(map[string]interface{}); replies[1]["id"] != 2.0 || err["code"] != float64(lspMethodNotFound) {
+		t.Errorf("hover of a lowercase header replied %v", replies[1])
+	}
+	if err, _ := replies[2]["error"].(map[string]interface{}); replies[2]["id"] != nil || err["code"] != float64(lspParseError) {
+		t.Errorf("bad JSON replied %v", replies[2])
+	}
+	if replies[3]["id"] != "s" {
+		t.Errorf("shutdown replied %v", replies[3])
+	}
+}
+
+func TestLSPExit(t *testing.T) {
+	tests := []struct {
+		in     string
+		status int
+	}{
+		{"", 1},
+		{lspMessage(map[string]string{"jsonrpc": "2.0", "method": "exit"}), 1},
+		{"Content-Type: text/plain\r\n\r\n{}", 1},
+		{"Content-Length: x\r\n\r\n{}", 1},
+		{"Content-Length: 10\r\n\r\n{}", 1},
+	}
+	for _, tt := range tests {
+		s, _ := newTestLSPServer(tt.in)
+		if status := s.run(); status != tt.status {
+			t.Errorf("run(%q) = %d, want %d", tt.in, status, tt.status)
+		}
+	}
+	s, _ := newTestLSPServer("Content-Type: text/plain\r\n\r\n{}")
+	if _, err := s.read(); err == nil || !strings.Contains(err.Error(), "missing Content-Length") {
+		t.Errorf("read() without Content-Length = %v", err)
+	}
+}
+
+func TestLSPPositions(t *testing.T) {
+	s, _ := newTestLSPServer("")
+	done := useFakeBackend(&fakeBackend{})
+	defer done()
+	// 😀 and 𝒳 are two UTF-16 code units, é is one
+	s.open("file:///a.txt", "plaintext", "a 😀 héllo\n𝒳 wörld\n\nend")
+	d := s.docs["file:///a.txt"]
+
+	tests := []struct {
+		offset int
+		pos    lspPosition
+	}{
+		{0, lspPosition{0, 0}},
+		{2, lspPosition{0, 2}},                                 // 😀
+		{strings.Index(d.text, "héllo"), lspPosition{0, 5}},    // after the surrogate pair
+		{strings.Index(d.text, "llo"), lspPosition{0, 7}},      // after é
+		{strings.Index(d.text, "\n𝒳"), lspPosition{0, 10}},     // the end of the line
+		{strings.Index(d.text, "𝒳"), lspPosition{1, 0}},        // a line start
+		{strings.Index(d.text, "wörld"), lspPosition{1, 3}},    // after 𝒳
+		{strings.Index(d.text, "end"), lspPosition{3, 0}},      // after an empty line
+		{len(d.text), lspPosition{3, 3}},                       // the end
+		{strings.Index(d.text, "\n\n") + 1, lspPosition{2, 0}}, // the empty line
+		{strings.Index(d.text, "rld"), lspPosition{1, 5}},      // after ö
+		{strings.Index(d.text, " héllo"), lspPosition{0, 4}},   // after 😀
+	}
+	for _, tt := range tests {
+		if got := d.position(tt.offset); got != tt.pos {
+			t.Errorf("position(%d) = %v, want %v", tt.offset, got, tt.pos)
+		}
+		if got := d.offset(tt.pos); got != tt.offset {
+			t.Errorf("offset(%v) = %d, want %d", tt.pos, got, tt.offset)
+		}
+	}
+
+	// the positions out of the text are clamped
+	clamped := []struct {
+		pos    lspPosition
+		offset int
+	}{
+		{lspPosition{-1, 0}, 0},
+		{lspPosition{0, 100}, strings.Index(d.text, "\n")},
+		{lspPosition{9, 0}, len(d.text)},
+		// the middle of a surrogate pair is after it
+		{lspPosition{0, 3}, strings.Index(d.text, " héllo")},
+	}
+	for _, tt := range clamped {
+		if got := d.offset(tt.pos); got != tt.offset {
+			t.Errorf("offset(%v) = %d, want %d", tt.pos, got, tt.offset)
+		}
+	}
+}
+
+func TestSpellableWords(t *testing.T) {
+	tests := []struct {
+		languageID, uri, text string
+		want                  []string
+	}{
+		{
+			"go", "file:///a.go",
+			"// Recieve the mesage\nfunc readMessage(s string) error {\n\treturn fmt.Errorf(\"bad hedaer %q\\n\", s) /* the ned */\n}",
+			[]string{"Recieve", "the", "mesage", "bad", "hedaer", "the", "ned"},
+		},
+		{
+			// identifiers, paths and URLs in the comments are skipped
+			"go", "file:///a.go",
+			"// see readMessage, snake_case, v2test, os.Args and http://exmaple.com/pth\nvar x = 1",
+			[]string{"see", "and"},
+		},
+		{
+			"python", "file:///a.py",
+			"# Teh comment\nx = 'strnig' // not a coment\n",
+			[]string{"Teh", "comment", "strnig"},
+		},
+		{
+			"markdown", "file:///a.md",
+			"# Titel\n\nSome `cdoe` and [lnik](http://exmaple.com).\n\n```\nnot chekced\n```\n",
+			[]string{"Titel", "Some", "and", "lnik"},
+		},
+		{
+			// the prose files are found by the extension too
+			"", "file:///notes.txt",
+			"it's a tset, don’t",
+			[]string{"it's", "tset", "don’t"},
+		},
+	}
+	for _, tt := range tests {
+		got := make([]string, 0)
+		for _, span := range spellableWords(tt.languageID, tt.uri, tt.text) {
+			got = append(got, tt.text[span[0]:span[1]])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("spellableWords(%s, %q) = %q, want %q", tt.languageID, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMaskCode(t *testing.T) {
+	tests := []struct {
+		text   string
+		syntax lspCommentSyntax
+		want   string
+	}{
+		{"x := 1 // note\ny", cComments, "        / note\n "},
+		{"a /* b\nc */ d", cComments, "     b\nc     "},
+		{`s := "a\nb" + 'c'`, cComments, `      a  b     c `},
+		{"s := `raw\\n`", cComments, "      raw\\n "},
+		{"x = 1 # note\n'''doc'''", hashComments, "        note\n   doc   "},
+		{"-- note\nselect 'a'", dashComments, " - note\n        a "},
+		// an unclosed string ends at the line end
+		{"\"abc\ndef", cComments, " abc\n   "},
+	}
+	for _, tt := range tests {
+		if got := string(maskCode(tt.text, tt.syntax)); got != tt.want {
+			t.Errorf("maskCode(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if got := maskCode(tt.text, tt.syntax); len(got) != len(tt.text) {
+			t.Errorf("maskCode(%q) changed the offsets", tt.text)
+		}
+	}
+}
+
+func TestLSPCodeActions(t *testing.T) {
+	b := &fakeBackend{
+		words:   map[string]string{"the": "", "note": ""},
+		guesses: map[string][]string{"Recieve": {"receive", "relieve"}},
+	}
+	done := useFakeBackend(b)
+	defer done()
+	dir, err := ioutil.TempDir("", "lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prev := personal
+	personal = &wordList{path: path.Join(dir, "words.json")}
+	defer func() { personal = prev }()
+
+	s, out := newTestLSPServer("")
+	uri := "file:///a.go"
+	s.open(uri, "go", "// the note\n// 😀 Recieve the note")
+	replies := lspReplies(t, out)
+	var published struct {
+		Params struct {
+			Diagnostics []lspDiagnostic
+		}
+	}
+	data, _ := json.Marshal(replies[0])
+	json.Unmarshal(data, &published)
+	diags := published.Params.Diagnostics
+	want := lspRange{lspPosition{1, 6}, lspPosition{1, 13}}
+	if len(diags) != 1 || diags[0].Range != want || diags[0].Code != "misspelled" {
+		t.Fatalf("the diagnostics are %+v, want Recieve at %v", diags, want)
+	}
+
+	actions := s.codeActions(uri, diags)
+	titles := make([]string, len(actions))
+	for n, a := range actions {
+		titles[n] = a.Title
+	}
+	if want := []string{"Change to “receive”", "Change to “relieve”", "Add “Recieve” to Personal Words"}; !reflect.DeepEqual(titles, want) {
+		t.Fatalf("the code actions are %q, want %q", titles, want)
+	}
+	edit := actions[0].Edit.(map[string]interface{})["changes"].(map[string][]lspTextEdit)[uri]
+	if !actions[0].IsPreferred || actions[1].IsPreferred || len(edit) != 1 || edit[0].NewText != "Receive" || edit[0].Range != want {
+		t.Errorf("the first guess edits %+v, preferred %t", edit, actions[0].IsPreferred)
+	}
+	if c := actions[2].Command; c == nil || c.Command != lspAddWordCommand || !reflect.DeepEqual(c.Arguments, []interface{}{"Recieve"}) {
+		t.Errorf("the add command is %+v", c)
+	}
+	if got := s.codeActions(uri, []lspDiagnostic{{Source: "other", Code: "misspelled", Range: want}}); len(got) != 0 {
+		t.Errorf("the diagnostics of other servers got %d code actions", len(got))
+	}
+
+	// adding the word republishes the documents without it
+	out.Reset()
+	args, _ := json.Marshal(lspCodeCommand{Command: lspAddWordCommand, Arguments: []interface{}{"Recieve"}})
+	if _, rerr := s.handle(lspRequest{Method: "workspace/executeCommand", Params: args}); rerr != nil {
+		t.Fatal(rerr)
+	}
+	if !personal.Has("Recieve") {
+		t.Error("Recieve is not in the personal words")
+	}
+	data, _ = json.Marshal(lspReplies(t, out)[0])
+	json.Unmarshal(data, &published)
+	if len(published.Params.Diagnostics) != 0 {
+		t.Errorf("the diagnostics after adding the word are %+v", published.Params.Diagnostics)
+	}
+}
+
+func TestMatchCase(t *testing.T) {
+	tests := []struct{ guess, word, want string }{
+		{"receive", "Recieve", "Receive"},
+		{"receive", "recieve", "receive"},
+		{"éclair", "Eclar", "Éclair"},
+		{"NASA", "nasa", "NASA"},
+	}
+	for _, tt := range tests {
+		if got := matchCase(tt.guess, tt.word); got != tt.want {
+			t.Errorf("matchCase(%q, %q) = %q, want %q", tt.guess, tt.word, got, tt.want)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"os/exec"
 	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
 
 	"time"
@@ -13,110 +15,536 @@ import (
 	"github.com/DHowett/go-plist"
 	sjson "github.com/bitly/go-simplejson"
 	. "github.com/nbjahan/go-launchbar"
+	"github.com/nbjahan/go-launchbar/bridge"
 )
 
 var InDev string
 
 var pb *Action
 var start = time.Now()
+var personal *wordList
+var history *lookupHistory
+var deck *reviewDeck
 
 var funcs = map[string]Func{
-	"openDictionary": func(c *Context) {
-		word := c.Input.FuncArg()
-		if pb.IsControlKey() {
-			word = c.Input.String()
+	"openDictionary": openDictionary,
+	"showEntry": func(c *Context) *Items {
+		// the modifiers paste or look up the word like the other results
+		if pb.IsShiftKey() || pb.IsControlKey() {
+			openDictionary(c)
+			return nil
 		}
+		word := c.Input.FuncArg()
+		history.Record(word)
+		return entryItems(word)
+	},
+	"showSynonyms": func(c *Context) *Items {
+		return synonymItems(synonyms(c.Input.FuncArg()))
+	},
+	"pasteOrOpen": func(c *Context) {
+		args := c.Input.FuncArgs()
 		if pb.IsShiftKey() {
-			exec.Command("osascript", "-e", fmt.Sprintf(`tell application "LaunchBar"
-       perform action "Paste in Frontmost Application" with string "%s"
-       end tell`, word)).Start()
+			paste(args[1])
 		} else {
-			exec.Command("open", "dict://"+url.QueryEscape(word)).Start()
+			openInDictionary(args[0])
+		}
+	},
+	"pasteExport": func(c *Context) {
+		args := c.Input.FuncArgs()
+		paste(exportWord(args[0], args[1]))
+	},
+	"setPasteFormat": func(c *Context) {
+		if format := c.Input.FuncArg(); isExportFormat(format) {
+			c.Config.Set("pasteFormat", format)
+			conf.PasteFormat = format
+		}
+	},
+	"exportFlashcards": func(c *Context) {
+		args := c.Input.FuncArgs()
+		p := flashcardsPath(args[0], args[1])
+		if _, err := exportFlashcards(args[0], args[1], p); err != nil {
+			logError(err)
+			return
+		}
+		exec.Command("open", "-R", p).Start()
+	},
+	"revealCard": func(c *Context) *Items {
+		return revealItems(c.Input.FuncArg())
+	},
+	"gradeCard": func(c *Context) {
+		args := c.Input.FuncArgs()
+		quality, err := strconv.Atoi(args[1])
+		if err != nil {
+			c.Logger.Println(err)
+			return
+		}
+		deck.Grade(args[0], quality, time.Now())
+		c.Action.ShowView("review")
+	},
+	"removeHistory": func(c *Context) {
+		history.Remove(c.Input.FuncArg())
+		c.Action.ShowView("history")
+	},
+	"addWord": func(c *Context) {
+		word, def := parseWordDefinition(c.Input.FuncArg())
+		if err := personal.Add(word, def); err != nil {
+			logError(err)
 		}
 	},
+	"removeWord": func(c *Context) {
+		if err := personal.Remove(c.Input.FuncArg()); err != nil {
+			logError(err)
+		}
+		if c.Config.GetString("view") == "words" {
+			c.Action.ShowView("words")
+		}
+	},
+	"editWebDictionary": editWebDictionary,
+	"addWebDictionary":  addWebDictionary,
 }
 
-func init() {
-	pb = NewAction("Live Dictionary", ConfigValues{
-		"actionDefaultScript": "dict",
-		"debug":               false,
-		"limit":               10,
-		"autoupdate":          true,
-	})
+func initAction() {
+	pb = NewAction("Dictionary: Define (Live)", defaultConfig)
 	pb.Config.Set("indev", InDev != "")
+	if err := loadSettings(pb.Config); err != nil {
+		pb.Logger.Println(err)
+	}
+	if err := useBackend(conf.Backend, conf.Lang, pb.Config); err != nil {
+		pb.Logger.Println(err)
+		useBackend("", conf.Lang, pb.Config)
+	}
+	loadUserData(pb.SupportPath())
+	deck = loadReviewDeck(pb.SupportPath())
+	deck.Sync(personal.List(), time.Now())
 }
 
 func main() {
+	if status, ok := runCommand(os.Args[1:]); ok {
+		os.Exit(status)
+	}
+
+	if os.Getenv("LB_SUPPORT_PATH") == "" && os.Getenv("alfred_workflow_data") == "" {
+		// run by rofi or dmenu
+		os.Setenv("LB_SUPPORT_PATH", supportPath())
+		os.Setenv("LB_CACHE_PATH", cachePath())
+	}
+	if out, ok := forwardToDaemon(); ok {
+		fmt.Println(out)
+		return
+	}
+	fmt.Println(runAction())
+	startDaemon()
+}
+
+// runAction runs the action for os.Args and the environment and returns its
+// output. The daemon runs it for each forwarded request.
+func runAction() string {
+	initAction()
+	if pb.IsActionURL() {
+		actionURLs.Run(pb)
+		return ""
+	}
 	pb.Init(funcs)
 
-	width := float64(300)
+	width := launchBarWidth()
+
+	if InDev != "" {
+		pb.Logger.Printf("in:\n%s\n", pb.Input.Raw())
+	}
+
+	in := pb.Input
+
+	v := pb.NewView("main")
+	q := strings.TrimSpace(in.String())
+	if strings.HasPrefix(q, "syn:") {
+		renderSynonyms(v, strings.TrimSpace(strings.TrimPrefix(q, "syn:")))
+	} else {
+		renderMainView(v, q, width)
+	}
+
+	renderWordsView(q)
+	renderHistoryView(q)
+	renderFlashcardsView()
+	renderReviewView()
+	renderWebView(q)
+	expireQuickLookPages()
+
+	out := pb.Run()
+
+	if false && InDev != "" {
+		nice := out
+		js, err := sjson.NewJson([]byte(out))
+		if err == nil {
+			b, err := js.EncodePretty()
+			if err == nil {
+				nice = string(b)
+			}
+		}
+		pb.Logger.Println("out:", string(nice))
+	}
+	return out
+}
+
+var (
+	windowWidth        = float64(300)
+	windowWidthModTime time.Time
+)
+
+// launchBarWidth returns the width of the LaunchBar window from its
+// preferences, which are read again only when they change
+func launchBarWidth() float64 {
 	LBPlistPath := os.ExpandEnv("$HOME/Library/Preferences/at.obdev.LaunchBar.plist")
+	fi, err := os.Stat(LBPlistPath)
+	if err != nil {
+		pb.Logger.Println(err)
+		return windowWidth
+	}
+	if fi.ModTime().Equal(windowWidthModTime) {
+		return windowWidth
+	}
 	if fd, err := os.Open(LBPlistPath); err == nil {
 		defer fd.Close()
 		var pl map[string]interface{}
 		decoder := plist.NewDecoder(fd)
 		if err := decoder.Decode(&pl); err == nil && pl["LaunchBarWindowWidth"] != nil {
-			width = float64(reflect.ValueOf(pl["LaunchBarWindowWidth"]).Float())
+			windowWidth = float64(reflect.ValueOf(pl["LaunchBarWindowWidth"]).Float())
+			windowWidthModTime = fi.ModTime()
 		} else if err != nil {
 			pb.Logger.Println(err)
 		}
 	} else {
 		pb.Logger.Println(err)
 	}
+	return windowWidth
+}
 
-	if InDev != "" {
-		pb.Logger.Printf("in:\n%s\n", pb.Input.Raw())
+func paste(s string) {
+	if runtime.GOOS != "darwin" {
+		copyText(s)
+		return
+	}
+	if !pb.IsLaunchBar() {
+		bridge.Run(bridge.SetClipboard(s), bridge.Tell("System Events", bridge.Keystroke("v", "command down")))
+		return
 	}
+	bridge.LaunchBar(bridge.Paste(s))
+}
 
-	in := pb.Input
+// openDictionary opens the word in Dictionary.app, pastes it with shift and
+// looks up the query instead with control
+func openDictionary(c *Context) {
+	word := c.Input.FuncArg()
+	if pb.IsControlKey() {
+		word = c.Input.String()
+	}
+	history.Record(word)
+	if pb.IsShiftKey() {
+		paste(exportWord(word, conf.PasteFormat))
+	} else {
+		openInDictionary(word)
+	}
+}
+
+// setOpenMods describes the ⇧ and ⌃ variants of openDictionary for the
+// launchers that show them, like Alfred
+func setOpenMods(i *Item, q string) {
+	i.SetModSubtitle("shift", "Paste as "+exportFormatNames[conf.PasteFormat])
+	if q != "" {
+		i.SetModSubtitle("ctrl", fmt.Sprintf("Look up “%s”", q))
+	}
+}
+
+// exportWord returns the entry of the word in the format, or the word itself
+// if it's not defined.
+func exportWord(word, format string) string {
+	if format == "" || format == "word" {
+		return word
+	}
+	e := defineEntry(word)
+	if e == nil {
+		return word
+	}
+	out, err := exportEntry(e, format)
+	if err != nil {
+		pb.Logger.Println(err)
+		return word
+	}
+	return out
+}
+
+func openInDictionary(word string) {
+	if runtime.GOOS != "darwin" {
+		exec.Command("xdg-open", "dict://"+url.QueryEscape(word)).Start()
+		return
+	}
+	exec.Command("open", "dict://"+url.QueryEscape(word)).Start()
+}
+
+// copyText copies s to the Wayland or X clipboard, for the Linux frontends
+// which can't paste in the frontmost application
+func copyText(s string) {
+	for _, cmd := range [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}} {
+		if _, err := exec.LookPath(cmd[0]); err != nil {
+			continue
+		}
+		c := exec.Command(cmd[0], cmd[1:]...)
+		c.Stdin = strings.NewReader(s)
+		if err := c.Run(); err != nil {
+			pb.Logger.Println(err)
+		}
+		return
+	}
+	pb.Logger.Println("no clipboard command found: install wl-clipboard, xclip or xsel")
+}
+
+func renderWordsView(q string) {
+	v := pb.NewView("words")
+	i := v.NewItem("Back to Dictionary")
+	i.SetIcon("com.apple.Dictionary")
+	i.SetRun(ShowViewFunc("main"))
+
+	if word, def := parseWordDefinition(q); word != "" && (def != "" || !personal.Has(word)) {
+		title := "Add “%s”"
+		if personal.Has(word) {
+			title = "Update “%s”"
+		}
+		i = v.NewItem(fmt.Sprintf(title, word))
+		i.SetSubtitle(def)
+		i.SetIcon("DictionaryOff")
+		i.Run("addWord", q)
+	}
+
+	for _, pw := range personal.Words {
+		if q != "" && !strings.Contains(strings.ToLower(pw.Word), strings.ToLower(q)) {
+			continue
+		}
+		i = v.NewItem(pw.Word)
+		if pw.Definition != "" {
+			i.SetSubtitle(pw.Definition + " — ↩ to remove")
+		} else {
+			i.SetSubtitle("↩ to remove")
+		}
+		i.SetIcon("DictionaryOn")
+		i.Run("removeWord", pw.Word)
+	}
+}
+
+func renderHistoryView(q string) {
+	v := pb.NewView("history")
+	i := v.NewItem("Back to Dictionary")
+	i.SetIcon("com.apple.Dictionary")
+	i.SetRun(ShowViewFunc("main"))
+
+	for _, e := range history.Search(q) {
+		i = v.NewItem(e.Word)
+		i.SetSubtitle(fmt.Sprintf("%d lookups, last on %s — ↩ to remove", e.Count, time.Unix(e.Last, 0).Format("Jan 2, 2006")))
+		i.SetIcon("DictionaryOn")
+		i.Run("removeHistory", e.Word)
+	}
+}
 
+// flatten returns the definition text on a single line without the headword
+func flatten(def string) string {
+	fields := strings.Fields(def)
+	if len(fields) > 0 {
+		fields = fields[1:]
+	}
+	def = strings.Join(fields, " ")
+	if pos := strings.Index(def, "▶"); pos != -1 {
+		def = def[pos+len("▶"):]
+	}
+	return strings.TrimSpace(def)
+}
+
+func renderFlashcardsView() {
+	v := pb.NewView("flashcards")
+	i := v.NewItem("Back to Dictionary")
+	i.SetIcon("com.apple.Dictionary")
+	i.SetRun(ShowViewFunc("main"))
+
+	sources := []struct{ name, title string }{{"words", "Personal Words"}, {"history", "History"}}
+	formats := []struct{ name, title string }{{"tsv", "Anki Text File (.tsv)"}, {"apkg", "Anki Package (.apkg)"}}
+	for _, s := range sources {
+		for _, f := range formats {
+			i = v.NewItem(fmt.Sprintf("Export %s as %s", s.title, f.title))
+			i.SetIcon("at.obdev.LaunchBar:CopyActionTemplate")
+			if f.name == "apkg" {
+				if _, err := sqlitePath(); err != nil {
+					i.SetSubtitle("Needs the sqlite3 command")
+					continue
+				}
+			}
+			i.SetSubtitle("Saved to ~/Downloads")
+			i.Run("exportFlashcards", s.name, f.name)
+		}
+	}
+}
+
+func renderReviewView() {
+	v := pb.NewView("review")
+	i := v.NewItem("Back to Dictionary")
+	i.SetIcon("com.apple.Dictionary")
+	i.SetRun(ShowViewFunc("main"))
+
+	due := deck.Due(time.Now())
+	if len(due) == 0 {
+		i = v.NewItem("Nothing to review")
+		if next, ok := deck.Next(); ok {
+			i.SetSubtitle("Next review: " + next.Format("Jan 2, 15:04"))
+		} else {
+			i.SetSubtitle("Add words to Personal Words to review them")
+		}
+		i.SetIcon("DictionaryOff")
+		i.SetRun(ShowViewFunc("words"))
+		return
+	}
+
+	c := due[0]
+	i = v.NewItem(c.Word)
+	i.SetSubtitle(fmt.Sprintf("%d due — → to reveal", len(due)))
+	i.SetIcon("DictionaryOn")
+	i.SetActionRunsInBackground(false)
+	i.SetActionReturnsItems(true)
+	i.Run("revealCard", c.Word)
+}
+
+// renderSynonyms renders the syn: query mode
+func renderSynonyms(v *View, word string) {
+	if word == "" {
+		return
+	}
+	groups := synonyms(word)
+	if len(groups) == 0 {
+		i := v.NewItem(fmt.Sprintf("No synonyms for “%s”", word))
+		if getThesaurus() == nil {
+			i.SetSubtitle("No MyThes thesaurus found for " + language())
+		}
+		i.SetIcon("DictionaryOff")
+		i.Run("openDictionary", word)
+		return
+	}
+	for _, item := range *synonymItems(groups) {
+		item.SetOrder(len(v.Items))
+		v.AddItem(item)
+	}
+}
+
+func renderMainView(v *View, q string, width float64) {
 	var i *Item
-	v := pb.NewView("main")
-	q := strings.TrimSpace(in.String())
-	definitions := lookup(q, int(pb.Config.GetInt("limit")))
+	definitions := lookup(q, conf.Limit)
 
+	if q == "" {
+		if word := wordOfTheDay(); word != "" {
+			i = v.NewItem(word)
+			subtitle := "Word of the Day"
+			if e := defineEntry(word); e != nil && e.Summary() != "" {
+				subtitle += " — " + e.Summary()
+				i.SetQuickLookURL(quickLookURL(e, source()))
+			}
+			i.SetSubtitle(truncateText(subtitle, int(width/7)))
+			i.SetIcon("com.apple.Dictionary")
+			i.SetActionRunsInBackground(false)
+			i.SetActionReturnsItems(true)
+			i.Run("showEntry", word)
+			setOpenMods(i, q)
+		}
+
+		seen := make(map[string]bool)
+		for _, e := range history.Recent(5) {
+			seen[e.Word] = true
+			i = v.NewItem(e.Word)
+			i.SetSubtitle("Recent")
+			i.SetIcon("DictionaryOn")
+			i.Run("openDictionary", e.Word)
+			setOpenMods(i, q)
+		}
+		for _, e := range history.Frequent(5) {
+			if seen[e.Word] {
+				continue
+			}
+			i = v.NewItem(e.Word)
+			i.SetSubtitle(fmt.Sprintf("Frequent (%d lookups)", e.Count))
+			i.SetIcon("DictionaryOn")
+			i.Run("openDictionary", e.Word)
+			setOpenMods(i, q)
+		}
+		i = v.NewItem("History")
+		i.SetSubtitle(fmt.Sprintf("%d words", len(history.Entries)))
+		i.SetIcon("DictionaryOff")
+		i.SetRun(ShowViewFunc("history"))
+
+		i = v.NewItem("Personal Words")
+		i.SetSubtitle(fmt.Sprintf("%d words", len(personal.Words)))
+		i.SetIcon("DictionaryOff")
+		i.SetRun(ShowViewFunc("words"))
+
+		i = v.NewItem("Review")
+		i.SetSubtitle(fmt.Sprintf("%d due", len(deck.Due(time.Now()))))
+		i.SetIcon("DictionaryOff")
+		i.SetRun(ShowViewFunc("review"))
+
+		i = v.NewItem("Flashcards")
+		i.SetSubtitle("Export words to Anki")
+		i.SetIcon("DictionaryOff")
+		i.SetRun(ShowViewFunc("flashcards"))
+
+		enabled := 0
+		ds := conf.WebDictionaries
+		for _, d := range ds {
+			if d.Enabled {
+				enabled++
+			}
+		}
+		i = v.NewItem("Web Dictionaries")
+		i.SetSubtitle(fmt.Sprintf("%d of %d on", enabled, len(ds)))
+		i.SetIcon("DictionaryOff")
+		i.SetRun(ShowViewFunc("web"))
+	}
+	if pw, ok := personal.Get(q); ok && pw.Definition != "" {
+		i = v.NewItem(pw.Word)
+		i.SetSubtitle(pw.Definition)
+		i.SetIcon("DictionaryOn")
+		i.Run("openDictionary", pw.Word)
+		setOpenMods(i, q)
+	}
 	if q != "" && len(definitions) == 0 {
-		i = v.NewItem(in.String())
+		i = v.NewItem(q)
 		i.SetIcon("com.apple.Dictionary")
 		i.Run("openDictionary", q)
+		setOpenMods(i, q)
 	}
+	first := len(v.Items)
 	for _, row := range definitions {
 		word := row[0]
-		def := row[1]
-		pos := strings.Index(def, "▶")
-		if pos != -1 {
-			def = def[pos+len("▶"):]
-		}
-
-		fields := strings.Fields(def)
-		maxChars := int(width / 7)
-		totalLen := 0
-		parts := make([]string, 0, len(fields))
-		for _, field := range fields {
-			l := len([]rune(field))
-			if totalLen+l+len("…") < maxChars {
-				parts = append(parts, field)
-				totalLen += l + 1
-			} else {
-				parts = append(parts, "…")
-				break
-			}
+		entry := parseEntry(row[1])
+		if entry.Headword == "" {
+			entry.Headword = word
 		}
-		def = strings.Join(parts, " ")
+		def := entry.Summary()
+		if def == "" {
+			def = flatten(row[1])
+		}
+
+		def = truncateText(def, int(width/7))
 
 		i = v.NewItem(word)
 		i.SetSubtitle(def)
 		i.SetIcon("DictionaryOn")
-		i.Run("openDictionary", word)
+		i.SetActionRunsInBackground(false)
+		i.SetActionReturnsItems(true)
+		i.Run("showEntry", word)
+		setOpenMods(i, q)
+		i.SetQuickLookURL(quickLookURL(entry, source()))
 	}
 	if len(definitions) > 0 {
 		if definitions[0][0] != q {
 			i = v.NewItem(q)
 			i.SetIcon("DictionaryOff")
 			i.Run("openDictionary", q)
+			setOpenMods(i, q)
 			i.SetSubtitle(q)
 		} else {
-			v.Items[0].SetIcon("com.apple.Dictionary")
+			v.Items[first].SetIcon("com.apple.Dictionary")
 		}
 
 		// i = v.NewItem("⌃+Enter to open Dictionary.app")
@@ -127,20 +555,29 @@ func main() {
 		// i.SetIcon("at.obdev.LaunchBar:CopyActionTemplate")
 		// i.SetAction("")
 	}
-
-	out := pb.Run()
-
-	if false && InDev != "" {
-		nice := out
-		js, err := sjson.NewJson([]byte(out))
-		if err == nil {
-			b, err := js.EncodePretty()
-			if err == nil {
-				nice = string(b)
-			}
+	if word, def := parseWordDefinition(q); word != "" {
+		switch {
+		case personal.Has(word) && def == "":
+			i = v.NewItem(fmt.Sprintf("Remove “%s” from Personal Words", word))
+			i.SetIcon("DictionaryOff")
+			i.Run("removeWord", word)
+		case personal.Has(word):
+			i = v.NewItem(fmt.Sprintf("Update “%s” in Personal Words", word))
+			i.SetSubtitle(def)
+			i.SetIcon("DictionaryOff")
+			i.Run("addWord", q)
+		default:
+			i = v.NewItem(fmt.Sprintf("Add “%s” to Personal Words", word))
+			i.SetSubtitle(def)
+			i.SetIcon("DictionaryOff")
+			i.Run("addWord", q)
+		}
+	}
+	// the web dictionaries for the words the dictionaries don't have
+	if q != "" && (len(definitions) == 0 || definitions[0][0] != q) {
+		for _, item := range webItems(q) {
+			item.SetOrder(len(v.Items))
+			v.AddItem(item)
 		}
-		pb.Logger.Println("out:", string(nice))
 	}
-
-	fmt.Println(out)
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/nbjahan/go-launchbar"
+	"github.com/nbjahan/go-launchbar/launchbartest"
+)
+
+// newTestAction returns a simulated LaunchBar running the action with the
+// fake backend and the config. The clipboard and Dictionary.app commands of
+// Linux and macOS write to the files clipboard and opened of lb.Dir.
+func newTestAction(t *testing.T, b *fakeBackend, config string) (*launchbartest.Env, func()) {
+	done := useFakeBackend(b)
+	lb, err := launchbartest.New(map[string]interface{}{"CFBundleIdentifier": livedicID, "CFBundleName": "Dictionary"})
+	if err != nil {
+		done()
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		path.Join(lb.SupportPath, "config.json"): config,
+		path.Join(lb.Dir, "bin", "wl-copy"):      "#!/bin/sh\ncat > \"$HOME/clipboard\"\n",
+		path.Join(lb.Dir, "bin", "xdg-open"):     "#!/bin/sh\nprintf %s \"$1\" > \"$HOME/opened\"\n",
+		path.Join(lb.Dir, "bin", "open"):         "#!/bin/sh\nprintf %s \"$1\" > \"$HOME/opened\"\n",
+	}
+	for p, data := range files {
+		if err := ioutil.WriteFile(p, []byte(data), 0755); err != nil {
+			lb.Close()
+			done()
+			t.Fatal(err)
+		}
+	}
+	return lb, func() {
+		lb.Close()
+		done()
+	}
+}
+
+// readLater returns the file written by a command the action started, "" if
+// it's not written in a second
+func readLater(p string) string {
+	for n := 0; n < 100; n++ {
+		if data, err := ioutil.ReadFile(p); err == nil && len(data) > 0 {
+			return string(data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return ""
+}
+
+// definitionItem returns the result of the main view that defines the word
+func definitionItem(t *testing.T, lb *launchbartest.Env, q, word string) *Item {
+	items, err := lb.Run(runAction, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range items {
+		if i.Item().Title == word && i.Item().FuncName == "showEntry" {
+			return i
+		}
+	}
+	t.Fatalf("no definition of %q in the results of %q", word, q)
+	return nil
+}
+
+func TestDefinitionMods(t *testing.T) {
+	b := &fakeBackend{words: map[string]string{"hello": "hello | | exclamation used as a greeting"}}
+	lb, done := newTestAction(t, b, `{"backend": "fake", "pasteFormat": "markdown"}`)
+	defer done()
+
+	i := definitionItem(t, lb, "hello", "hello")
+	want := map[string]string{"shift": "Paste as Markdown", "ctrl": "Look up “hello”"}
+	if got := i.Item().Mods; !reflect.DeepEqual(got, want) {
+		t.Errorf("the definition has the mods %q, want %q", got, want)
+	}
+
+	// the Script Filter rows of Alfred
+	lb.Vars["LB_OUTPUT"] = "alfred"
+	var out string
+	if _, err := lb.Run(func() string { out = runAction(); return "" }, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	var filter struct {
+		Items []struct {
+			Title string
+			Mods  map[string]struct {
+				Subtitle  string
+				Variables map[string]string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &filter); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	for _, item := range filter.Items {
+		if item.Title != "hello" {
+			continue
+		}
+		for mod, v := range map[string]string{"shift": "LB_OPTION_SHIFT_KEY", "ctrl": "LB_OPTION_CONTROL_KEY"} {
+			if m := item.Mods[mod]; m.Subtitle != want[mod] || m.Variables[v] != "1" {
+				t.Errorf("the Alfred %s mod of hello is %+v", mod, m)
+			}
+		}
+		return
+	}
+	t.Errorf("no hello in the Alfred output %s", out)
+}
+
+func TestShowEntryModifiers(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("pastes with LaunchBar")
+	}
+	def := "hello | | exclamation used as a greeting"
+	b := &fakeBackend{words: map[string]string{"hello": def}, guesses: map[string][]string{"hel": {"hello"}}}
+	lb, done := newTestAction(t, b, `{"backend": "fake", "pasteFormat": "word"}`)
+	defer done()
+	arg := launchbartest.ItemArg(definitionItem(t, lb, "hel", "hello"))
+
+	// ⇧ pastes the word
+	lb.Shift = true
+	if items, err := lb.Run(runAction, arg); err != nil || len(items) != 0 {
+		t.Fatalf("⇧ returned %d items, %v", len(items), err)
+	}
+	if got := readLater(path.Join(lb.Dir, "clipboard")); got != "hello" {
+		t.Errorf("⇧ pasted %q, want hello", got)
+	}
+
+	// ⌃ looks up the query in Dictionary.app
+	lb.Shift, lb.Control = false, true
+	if items, err := lb.Run(runAction, arg); err != nil || len(items) != 0 {
+		t.Fatalf("⌃ returned %d items, %v", len(items), err)
+	}
+	if got := readLater(path.Join(lb.Dir, "opened")); got != "dict://hel" {
+		t.Errorf("⌃ opened %q, want dict://hel", got)
+	}
+
+	// and ↩ browses the entry
+	lb.Control = false
+	items, err := lb.Run(runAction, arg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) == 0 || !strings.Contains(items[0].Item().Title, "hello") {
+		t.Errorf("↩ returned %d items, want the entry of hello", len(items))
+	}
+}
+
+func TestPasteFormat(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("pastes with LaunchBar")
+	}
+	def := "hello | həˈlō | exclamation used as a greeting: hello there!"
+	for _, format := range []string{"word", "markdown", "text", "json"} {
+		b := &fakeBackend{words: map[string]string{"hello": def}}
+		lb, done := newTestAction(t, b, `{"backend": "fake", "pasteFormat": "`+format+`"}`)
+		arg := launchbartest.ItemArg(definitionItem(t, lb, "hello", "hello"))
+		lb.Shift = true
+		if _, err := lb.Run(runAction, arg); err != nil {
+			t.Fatal(err)
+		}
+		want, _ := exportEntry(parseEntry(def), format)
+		if got := readLater(path.Join(lb.Dir, "clipboard")); got != want {
+			t.Errorf("⇧ with the %s format pasted %q, want %q", format, got, want)
+		}
+		done()
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// quickLookTTL is how long an unused QuickLook page is kept in the cache
+const quickLookTTL = 7 * 24 * time.Hour
+
+var quickLookTemplate = template.Must(template.New("quicklook").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Headword}}</title>
+<style>
+body { font: 14px/1.5 -apple-system, "Helvetica Neue", sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 2em; margin: 0; }
+.pron { color: #777; margin-left: .5em; }
+.source { color: #999; font-size: .85em; margin-bottom: 1.5em; }
+h2 { font-size: 1.1em; font-style: italic; color: #8a1c1c; margin: 1.2em 0 .3em; }
+h2 small { font-style: normal; color: #777; font-weight: normal; }
+ol { margin: 0; padding-left: 1.5em; }
+li { margin: .4em 0; }
+li.sub { list-style: none; }
+li.sub:before { content: "• "; margin-left: -1em; }
+.labels { color: #777; font-style: italic; }
+.example { color: #555; font-style: italic; display: block; }
+.chain { font-weight: 600; }
+h3 { font-size: .8em; letter-spacing: .1em; color: #777; margin: 1.5em 0 .3em; }
+@media (prefers-color-scheme: dark) { body { background: #1e1e1e; color: #ddd; } h2 { color: #e07a7a; } }
+</style>
+</head>
+<body>
+<h1>{{.Headword}}{{if .Pronunciation}}<span class="pron">| {{.Pronunciation}} |</span>{{end}}</h1>
+<div class="source">{{.Source}}</div>
+{{range .Parts}}
+{{if .Name}}<h2>{{.Name}} {{if .Info}}<small>{{.Info}}</small>{{end}}</h2>{{end}}
+<ol>
+{{range .Senses}}<li{{if .Sub}} class="sub"{{else if .Number}} value="{{.Number}}"{{end}}>
+{{if .Labels}}<span class="labels">{{range .Labels}}{{.}} {{end}}</span>{{end}}{{.Definition}}
+{{range .Examples}}<span class="example">{{.}}</span>{{end}}
+</li>
+{{end}}
+</ol>
+{{end}}
+{{if .Phrases}}<h3>PHRASES</h3>{{range .Phrases}}<p>{{.}}</p>{{end}}{{end}}
+{{if .Derivatives}}<h3>DERIVATIVES</h3><p>{{range $i, $d := .Derivatives}}{{if $i}}, {{end}}{{$d}}{{end}}</p>{{end}}
+{{if .Origin}}<h3>ORIGIN</h3>{{if .OriginChain}}<p class="chain">{{range $i, $l := .OriginChain}}{{if $i}} → {{end}}{{$l}}{{end}}</p>{{end}}<p>{{.Origin}}</p>{{end}}
+</body>
+</html>
+`))
+
+type quickLookData struct {
+	*Entry
+	Source string
+}
+
+// quickLookURL renders the entry as an html page in the cache path and returns
+// its file URL. The pages are content addressed so the same entry is written
+// only once.
+func quickLookURL(e *Entry, source string) string {
+	var buf bytes.Buffer
+	if err := quickLookTemplate.Execute(&buf, quickLookData{e, source}); err != nil {
+		pb.Logger.Println(err)
+		return ""
+	}
+
+	dir := path.Join(pb.CachePath(), "quicklook")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		pb.Logger.Println(err)
+		return ""
+	}
+	sum := sha1.Sum(buf.Bytes())
+	p := path.Join(dir, hex.EncodeToString(sum[:])+".html")
+	if _, err := os.Stat(p); err == nil {
+		// keep it fresh
+		now := time.Now()
+		os.Chtimes(p, now, now)
+	} else if err := ioutil.WriteFile(p, buf.Bytes(), 0644); err != nil {
+		pb.Logger.Println(err)
+		return ""
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// expireQuickLookPages removes the pages that are not used in quickLookTTL.
+// It runs at most once a day.
+func expireQuickLookPages() {
+	var last time.Time
+	if _, err := pb.Cache.Get("quicklookExpiry", &last); err == nil {
+		return
+	}
+	pb.Cache.Set("quicklookExpiry", time.Now(), 24*time.Hour)
+
+	files, _ := filepath.Glob(path.Join(pb.CachePath(), "quicklook", "*.html"))
+	for _, f := range files {
+		if stat, err := os.Stat(f); err == nil && time.Since(stat.ModTime()) > quickLookTTL {
+			os.Remove(f)
+		}
+	}
+}
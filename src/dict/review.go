@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// grades of a recall, in the SM-2 quality scale
+var grades = []struct {
+	Name    string
+	Quality int
+}{
+	{"Again", 1},
+	{"Hard", 3},
+	{"Good", 4},
+	{"Easy", 5},
+}
+
+const initialEase = 2.5
+const minEase = 1.3
+
+// reviewCard is the SM-2 schedule of a word
+type reviewCard struct {
+	Word     string  `json:"word"`
+	Ease     float64 `json:"ease"`
+	Interval int     `json:"interval"` // days
+	Reps     int     `json:"reps"`     // successful reviews in a row
+	Lapses   int     `json:"lapses"`
+	Due      int64   `json:"due"` // unix time
+}
+
+// Grade schedules the next review with SM-2 for a recall quality of 0-5
+func (c *reviewCard) Grade(quality int, now time.Time) {
+	if quality < 3 {
+		c.Reps = 0
+		c.Interval = 1
+		c.Lapses++
+	} else {
+		switch c.Reps {
+		case 0:
+			c.Interval = 1
+		case 1:
+			c.Interval = 6
+		default:
+			c.Interval = int(math.Ceil(float64(c.Interval) * c.Ease))
+		}
+		c.Reps++
+	}
+	q := float64(5 - quality)
+	c.Ease += 0.1 - q*(0.08+q*0.02)
+	if c.Ease < minEase {
+		c.Ease = minEase
+	}
+	if quality < 3 {
+		// see it again in this session
+		c.Due = now.Add(10 * time.Minute).Unix()
+	} else {
+		c.Due = now.AddDate(0, 0, c.Interval).Unix()
+	}
+}
+
+// reviewDeck is the review schedule stored in the action's support path
+type reviewDeck struct {
+	path  string
+	Cards []reviewCard `json:"cards"`
+}
+
+func loadReviewDeck(dir string) *reviewDeck {
+	d := &reviewDeck{path: path.Join(dir, "review.json")}
+	if data, err := ioutil.ReadFile(d.path); err == nil {
+		json.Unmarshal(data, d)
+	}
+	return d
+}
+
+func (d *reviewDeck) index(word string) int {
+	for i, c := range d.Cards {
+		if strings.EqualFold(c.Word, word) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Sync adds the new words as due cards and removes the cards of the words
+// that are gone.
+func (d *reviewDeck) Sync(words []string, now time.Time) {
+	changed := false
+	keep := make(map[string]bool, len(words))
+	for _, w := range words {
+		keep[strings.ToLower(w)] = true
+		if d.index(w) == -1 {
+			d.Cards = append(d.Cards, reviewCard{Word: w, Ease: initialEase, Due: now.Unix()})
+			changed = true
+		}
+	}
+	cards := d.Cards[:0]
+	for _, c := range d.Cards {
+		if keep[strings.ToLower(c.Word)] {
+			cards = append(cards, c)
+		} else {
+			changed = true
+		}
+	}
+	d.Cards = cards
+	if changed {
+		d.save()
+	}
+}
+
+// Due returns the cards due at now, the most overdue first
+func (d *reviewDeck) Due(now time.Time) []reviewCard {
+	due := make([]reviewCard, 0)
+	for _, c := range d.Cards {
+		if c.Due <= now.Unix() {
+			due = append(due, c)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Due < due[j].Due })
+	return due
+}
+
+// Next returns the time of the next due card
+func (d *reviewDeck) Next() (time.Time, bool) {
+	if len(d.Cards) == 0 {
+		return time.Time{}, false
+	}
+	next := d.Cards[0].Due
+	for _, c := range d.Cards[1:] {
+		if c.Due < next {
+			next = c.Due
+		}
+	}
+	return time.Unix(next, 0), true
+}
+
+// Grade grades the recall of the word and persists the next schedule
+func (d *reviewDeck) Grade(word string, quality int, now time.Time) {
+	i := d.index(word)
+	if i == -1 {
+		return
+	}
+	d.Cards[i].Grade(quality, now)
+	d.save()
+}
+
+func (d *reviewDeck) save() {
+	if data, err := json.Marshal(d); err == nil {
+		ioutil.WriteFile(d.path, data, 0664)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGrade(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		name    string
+		card    reviewCard
+		quality int
+		want    reviewCard
+		due     time.Duration
+	}{
+		{"a new card", reviewCard{Ease: initialEase}, 4,
+			reviewCard{Ease: 2.5, Interval: 1, Reps: 1}, day},
+		{"the second review", reviewCard{Ease: 2.5, Interval: 1, Reps: 1}, 5,
+			reviewCard{Ease: 2.6, Interval: 6, Reps: 2}, 6 * day},
+		{"the interval times the ease", reviewCard{Ease: 2.5, Interval: 6, Reps: 2}, 4,
+			reviewCard{Ease: 2.5, Interval: 15, Reps: 3}, 15 * day},
+		// the ease before the grade, rounded up
+		{"a hard recall", reviewCard{Ease: 2.36, Interval: 15, Reps: 3}, 3,
+			reviewCard{Ease: 2.22, Interval: 36, Reps: 4}, 36 * day},
+		{"the ease floor", reviewCard{Ease: 1.35, Interval: 6, Reps: 2}, 3,
+			reviewCard{Ease: minEase, Interval: 9, Reps: 3}, 9 * day},
+		{"the ease floor on a lapse", reviewCard{Ease: 1.4, Interval: 9, Reps: 3}, 2,
+			reviewCard{Ease: minEase, Interval: 1, Lapses: 1}, 10 * time.Minute},
+		{"a lapse", reviewCard{Ease: 2.5, Interval: 40, Reps: 4, Lapses: 2}, 1,
+			reviewCard{Ease: 1.96, Interval: 1, Lapses: 3}, 10 * time.Minute},
+		{"a blackout", reviewCard{Ease: 2.5, Interval: 6, Reps: 2}, 0,
+			reviewCard{Ease: 1.7, Interval: 1, Lapses: 1}, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		c := tt.card
+		c.Grade(tt.quality, now)
+		if math.Abs(c.Ease-tt.want.Ease) > 1e-9 {
+			t.Errorf("%s: the ease is %v, want %v", tt.name, c.Ease, tt.want.Ease)
+		}
+		if c.Interval != tt.want.Interval || c.Reps != tt.want.Reps || c.Lapses != tt.want.Lapses {
+			t.Errorf("%s: the interval, reps and lapses are %d, %d, %d, want %d, %d, %d", tt.name,
+				c.Interval, c.Reps, c.Lapses, tt.want.Interval, tt.want.Reps, tt.want.Lapses)
+		}
+		if due := time.Unix(c.Due, 0).Sub(now); due != tt.due {
+			t.Errorf("%s: due in %v, want %v", tt.name, due, tt.due)
+		}
+	}
+}
+
+func TestGradeRelearns(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := reviewCard{Ease: initialEase}
+	intervals := make([]int, 0)
+	for _, q := range []int{4, 4, 4, 1, 4, 4} {
+		c.Grade(q, now)
+		intervals = append(intervals, c.Interval)
+	}
+	// the steps start over after the lapse, with the lower ease
+	want := []int{1, 6, 15, 1, 1, 6}
+	for n := range want {
+		if intervals[n] != want[n] {
+			t.Fatalf("the intervals are %v, want %v", intervals, want)
+		}
+	}
+	if c.Reps != 2 || c.Lapses != 1 || math.Abs(c.Ease-1.96) > 1e-9 {
+		t.Errorf("the card is %+v", c)
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serveTokenEnv holds the bearer token of dict serve, so it's not shown in
+// the process list
+const serveTokenEnv = "DICT_SERVE_TOKEN"
+
+// server answers the HTTP API of dict serve:
+//
+//	GET /define?q=word[&limit=n]   the entries of the word, like define --json
+//	GET /suggest?q=query[&limit=n] the words the action lists for the query
+//	GET /complete?q=prefix         the known words starting with the prefix
+//	GET /entry/{id}                the entry of a headword (the id of /suggest)
+//	GET /events?q=query            the suggestions and their entries as
+//	                               Server-Sent Events, for the live search
+//	GET /                          the live search page
+//
+// The errors are {"error": "..."} with a 4xx or 5xx status.
+type server struct {
+	token   string
+	timeout time.Duration
+	limit   int
+}
+
+type serveSuggestion struct {
+	ID string `json:"id"`
+	suggestion
+}
+
+type serveError struct {
+	Error string `json:"error"`
+}
+
+func serveCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8710", "address to listen on")
+	timeout := fs.Duration("timeout", 10*time.Second, "maximum time of a lookup")
+	backend := fs.String("backend", "", "dictionary backend: "+strings.Join(backendNames(), ", ")+" (default: the backend config)")
+	lang := fs.String("lang", "", "spelling language, e.g. en_GB (default: the lang config)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dict serve [-addr host:port] [-timeout 10s] [-backend name] [-lang code]")
+		fmt.Fprintf(os.Stderr, "\nSet %s to require \"Authorization: Bearer <token>\" (needed to listen beyond localhost).\n\n", serveTokenEnv)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if _, err := initCommand(*backend, *lang); err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 2
+	}
+
+	s := &server{token: os.Getenv(serveTokenEnv), timeout: *timeout, limit: conf.Limit}
+	if s.token == "" && !isLoopback(*addr) {
+		fmt.Fprintf(os.Stderr, "dict: set %s to listen on %s\n", serveTokenEnv, *addr)
+		return 2
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "dict: serving %s on http://%s/\n", source(), ln.Addr())
+	srv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	if err := srv.Serve(ln); err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// isLoopback returns true if addr only listens on the local host
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/define", s.define)
+	mux.HandleFunc("/suggest", s.suggest)
+	mux.HandleFunc("/complete", s.complete)
+	mux.HandleFunc("/entry/", s.entry)
+	mux.HandleFunc("/events", s.events)
+	mux.HandleFunc("/", s.page)
+	return s.authorize(mux)
+}
+
+// authorize checks the bearer token. EventSource can't send headers, so the
+// token is also accepted in the token parameter.
+func (s *server) authorize(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" || token == r.Header.Get("Authorization") {
+				token = r.URL.Query().Get("token")
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="dict"`)
+				writeJSON(w, http.StatusUnauthorized, serveError{"invalid or missing token"})
+				return
+			}
+		}
+		if r.Method != "GET" && r.Method != "HEAD" {
+			writeJSON(w, http.StatusMethodNotAllowed, serveError{"only GET is supported"})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// query returns the q parameter and the limit, writing the error if they are
+// missing or invalid
+func (s *server) query(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeJSON(w, http.StatusBadRequest, serveError{"missing q parameter"})
+		return "", 0, false
+	}
+	limit := s.limit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, serveError{"invalid limit: " + l})
+			return "", 0, false
+		}
+		limit = n
+	}
+	return q, limit, true
+}
+
+// lookup runs lookupContext within the request timeout. ok is false when
+// the lookup timed out or the client went away; the error is written then.
+func (s *server) lookup(w http.ResponseWriter, r *http.Request, q string, limit int) ([]*Entry, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	entries := lookupEntriesContext(ctx, q, limit)
+	if ctx.Err() != nil {
+		writeJSON(w, http.StatusGatewayTimeout, serveError{"lookup timed out"})
+		return nil, false
+	}
+	return entries, true
+}
+
+func (s *server) define(w http.ResponseWriter, r *http.Request) {
+	q, limit, ok := s.query(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("limit") == "" {
+		// like dict define, only the best match
+		limit = 1
+	}
+	entries, ok := s.lookup(w, r, q, limit)
+	if !ok {
+		return
+	}
+	if len(entries) == 0 {
+		writeJSON(w, http.StatusNotFound, serveError{fmt.Sprintf("no definition for %q", q)})
+		return
+	}
+	out := make([]exportedEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, exportedEntry{exportSchemaVersion, source(), e})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func suggestions(entries []*Entry) []serveSuggestion {
+	out := make([]serveSuggestion, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, serveSuggestion{url.PathEscape(e.Headword), suggestion{e.Headword, summary(e)}})
+	}
+	return out
+}
+
+func (s *server) suggest(w http.ResponseWriter, r *http.Request) {
+	q, limit, ok := s.query(w, r)
+	if !ok {
+		return
+	}
+	entries, ok := s.lookup(w, r, q, limit)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, suggestions(entries))
+}
+
+// complete returns the personal words, the history and the spelling guesses
+// that start with the prefix, the most looked up first. It doesn't look up
+// the definitions, so it's fast enough for an editor's completion.
+func (s *server) complete(w http.ResponseWriter, r *http.Request) {
+	q, limit, ok := s.query(w, r)
+	if !ok {
+		return
+	}
+	prefix := strings.ToLower(q)
+	seen := make(map[string]bool)
+	words := make([]string, 0)
+	add := func(word string) {
+		key := strings.ToLower(word)
+		if !seen[key] && strings.HasPrefix(key, prefix) {
+			seen[key] = true
+			words = append(words, word)
+		}
+	}
+	if personal != nil {
+		for _, word := range personal.List() {
+			add(word)
+		}
+	}
+	if history != nil {
+		for _, e := range history.Entries {
+			add(e.Word)
+		}
+	}
+	for _, word := range spell(q) {
+		add(word)
+	}
+	sortByHistory(words)
+	if len(words) > limit {
+		words = words[:limit]
+	}
+	writeJSON(w, http.StatusOK, words)
+}
+
+func (s *server) entry(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/entry/")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, serveError{"missing entry id"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	e := defineEntryContext(ctx, id)
+	if ctx.Err() != nil {
+		writeJSON(w, http.StatusGatewayTimeout, serveError{"lookup timed out"})
+		return
+	}
+	if e == nil {
+		writeJSON(w, http.StatusNotFound, serveError{fmt.Sprintf("no entry %q", id)})
+		return
+	}
+	writeJSON(w, http.StatusOK, exportedEntry{exportSchemaVersion, source(), e})
+}
+
+// events sends a suggestions event with the results of the query, an entry
+// event for each of them and a done event. The page opens a new stream for
+// every query, closing the stream cancels its lookup.
+func (s *server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, serveError{"streaming is not supported"})
+		return
+	}
+	q, limit, ok := s.query(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	send := func(event string, v interface{}) {
+		b, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+		flusher.Flush()
+	}
+
+	entries := lookupEntriesContext(ctx, q, limit)
+	if ctx.Err() != nil {
+		send("failure", serveError{"lookup timed out"})
+		return
+	}
+	send("suggestions", suggestions(entries))
+	for _, e := range entries {
+		send("entry", exportedEntry{exportSchemaVersion, source(), e})
+	}
+	send("done", q)
+}
+
+func (s *server) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeJSON(w, http.StatusNotFound, serveError{"not found"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, servePage)
+}
+
+// servePage is the live search page. It passes its own token parameter on
+// to the event streams.
+const servePage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>dict</title>
+<style>
+body { font: 14px/1.5 -apple-system, "Helvetica Neue", sans-serif; margin: 2em auto; max-width: 50em; padding: 0 1em; color: #222; }
+input { font: inherit; font-size: 1.4em; width: 100%; box-sizing: border-box; padding: .3em .5em; }
+#status { color: #999; font-size: .85em; margin: .3em 0 1em; }
+.entry { border-top: 1px solid #ddd; padding: .5em 0; }
+.entry h1 { font-size: 1.3em; margin: 0; cursor: pointer; }
+.pron { color: #777; font-weight: normal; margin-left: .5em; }
+.summary { color: #555; }
+.senses { display: none; }
+.open .senses { display: block; }
+.open .summary { display: none; }
+h2 { font-size: 1em; font-style: italic; color: #8a1c1c; margin: .8em 0 .2em; }
+ol { margin: 0; padding-left: 1.5em; }
+.example { color: #555; font-style: italic; display: block; }
+@media (prefers-color-scheme: dark) { body { background: #1e1e1e; color: #ddd; } h2 { color: #e07a7a; } .summary, .example { color: #aaa; } }
+</style>
+</head>
+<body>
+<input id="q" placeholder="Look up…" autofocus autocomplete="off">
+<div id="status"></div>
+<div id="results"></div>
+<script>
+var token = new URLSearchParams(location.search).get("token");
+var q = document.getElementById("q"), status = document.getElementById("status"), results = document.getElementById("results");
+var stream = null, timer = null;
+
+function el(tag, cls, text) {
+  var e = document.createElement(tag);
+  if (cls) e.className = cls;
+  if (text) e.textContent = text;
+  return e;
+}
+
+function search() {
+  if (stream) stream.close();
+  var query = q.value.trim();
+  if (!query) { results.innerHTML = ""; status.textContent = ""; return; }
+  var params = new URLSearchParams({q: query});
+  if (token) params.set("token", token);
+  status.textContent = "Looking up…";
+  stream = new EventSource("events?" + params);
+  stream.addEventListener("suggestions", function (ev) {
+    results.innerHTML = "";
+    var list = JSON.parse(ev.data);
+    status.textContent = list.length ? list.length + " results" : "Nothing found";
+    list.forEach(function (s) {
+      var div = el("div", "entry");
+      div.dataset.word = s.word;
+      var h = el("h1", "", s.word);
+      h.onclick = function () { div.classList.toggle("open"); };
+      div.appendChild(h);
+      div.appendChild(el("div", "summary", s.summary));
+      div.appendChild(el("div", "senses"));
+      results.appendChild(div);
+    });
+  });
+  stream.addEventListener("entry", function (ev) {
+    var e = JSON.parse(ev.data);
+    var div = [].find.call(results.children, function (d) { return d.dataset.word === e.headword; });
+    if (!div) return;
+    if (e.pronunciation) div.querySelector("h1").appendChild(el("span", "pron", "| " + e.pronunciation + " |"));
+    var senses = div.querySelector(".senses");
+    (e.parts || []).forEach(function (p) {
+      if (p.name) senses.appendChild(el("h2", "", p.name + (p.info ? " " + p.info : "")));
+      var ol = el("ol");
+      (p.senses || []).forEach(function (s) {
+        var li = el("li", "", (s.labels ? s.labels.join(" ") + " " : "") + s.definition);
+        (s.examples || []).forEach(function (x) { li.appendChild(el("span", "example", x)); });
+        ol.appendChild(li);
+      });
+      senses.appendChild(ol);
+    });
+  });
+  stream.addEventListener("failure", function (ev) { status.textContent = JSON.parse(ev.data).error; });
+  stream.addEventListener("done", function () { stream.close(); });
+  stream.onerror = function () { status.textContent = "The server is not reachable"; stream.close(); };
+}
+
+q.addEventListener("input", function () {
+  clearTimeout(timer);
+  timer = setTimeout(search, 150);
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveBackend defines hello and help, and guesses them for "hel"
+func serveBackend() *fakeBackend {
+	return &fakeBackend{
+		words: map[string]string{
+			"hello": "hel·lo | həˈlō | exclamation used as a greeting",
+			"help":  "help | help | verb make it easier for someone",
+		},
+		guesses: map[string][]string{"hel": {"hello", "help"}},
+	}
+}
+
+// get runs the request on the server's handler
+func get(s *server, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	return w
+}
+
+func TestServeLookups(t *testing.T) {
+	done := useFakeBackend(serveBackend())
+	defer done()
+	s := &server{timeout: time.Second, limit: 5}
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/suggest?q=hel", 200, `[{"id":"hello","word":"hello","summary":"used as a greeting"},{"id":"help","word":"help","summary":"make it easier for someone"}]`},
+		{"/suggest?q=hel&limit=1", 200, `[{"id":"hello","word":"hello","summary":"used as a greeting"}]`},
+		{"/suggest?q=zzz", 200, `[]`},
+		{"/suggest", 400, `{"error":"missing q parameter"}`},
+		{"/suggest?q=hel&limit=0", 400, `{"error":"invalid limit: 0"}`},
+		{"/entry/hello", 200, `{"version":1,"source":"fake","headword":"hello","pronunciation":"həˈlō","parts":[{"name":"exclamation","senses":[{"definition":"used as a greeting"}]}]}`},
+		{"/entry/zzz", 404, `{"error":"no entry \"zzz\""}`},
+		{"/entry/", 400, `{"error":"missing entry id"}`},
+	}
+	for _, tt := range tests {
+		w := get(s, tt.target)
+		if body := strings.TrimSpace(w.Body.String()); w.Code != tt.status || body != tt.body {
+			t.Errorf("GET %s = %d %s, want %d %s", tt.target, w.Code, body, tt.status, tt.body)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("GET %s has the Content-Type %q", tt.target, ct)
+		}
+	}
+
+	var entries []exportedEntry
+	w := get(s, "/define?q=hello")
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil || len(entries) != 1 || entries[0].Headword != "hello" {
+		t.Errorf("GET /define?q=hello = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestServeTimeout(t *testing.T) {
+	b := serveBackend()
+	// the request is canceled during the lookup
+	b.wait = func(string) { time.Sleep(20 * time.Millisecond) }
+	done := useFakeBackend(b)
+	defer done()
+	s := &server{timeout: 5 * time.Millisecond, limit: 5}
+	for _, target := range []string{"/suggest?q=hel", "/define?q=hello", "/entry/hello"} {
+		if w := get(s, target); w.Code != http.StatusGatewayTimeout {
+			t.Errorf("GET %s = %d %s, want a timeout", target, w.Code, w.Body)
+		}
+	}
+}
+
+func TestServeAuthorize(t *testing.T) {
+	done := useFakeBackend(serveBackend())
+	defer done()
+	s := &server{token: "secret", timeout: time.Second, limit: 5}
+	tests := []struct {
+		method, target, auth string
+		status               int
+	}{
+		{"GET", "/entry/hello", "", 401},
+		{"GET", "/entry/hello", "Bearer wrong", 401},
+		{"GET", "/entry/hello", "Bearer secret", 200},
+		{"GET", "/entry/hello?token=secret", "", 200},
+		{"POST", "/entry/hello", "Bearer secret", 405},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		s.handler().ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s %s with %q = %d, want %d", tt.method, tt.target, tt.auth, w.Code, tt.status)
+		}
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	done := useFakeBackend(serveBackend())
+	defer done()
+	s := &server{timeout: time.Second, limit: 5}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events?q=hel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("the stream has the Content-Type %q", ct)
+	}
+
+	type event struct{ name, data string }
+	events := make([]event, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	var e event
+	for scanner.Scan() {
+		switch l := scanner.Text(); {
+		case strings.HasPrefix(l, "event: "):
+			e.name = strings.TrimPrefix(l, "event: ")
+		case strings.HasPrefix(l, "data: "):
+			e.data = strings.TrimPrefix(l, "data: ")
+		case l == "":
+			events = append(events, e)
+			e = event{}
+		}
+	}
+	names := make([]string, len(events))
+	for n, e := range events {
+		names[n] = e.name
+	}
+	if strings.Join(names, ",") != "suggestions,entry,entry,done" {
+		t.Fatalf("the events are %q, want suggestions, the entries and done", names)
+	}
+	var entry exportedEntry
+	if err := json.Unmarshal([]byte(events[2].data), &entry); err != nil || entry.Headword != "help" {
+		t.Errorf("the second entry is %s", events[2].data)
+	}
+	if events[3].data != `"hel"` {
+		t.Errorf("done sent %s, want the query", events[3].data)
+	}
+
+	// a missing query is a JSON error, not a stream
+	resp, err = http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 400 || !strings.Contains(string(body), "missing q") {
+		t.Errorf("GET /events = %d %s", resp.StatusCode, body)
+	}
+}
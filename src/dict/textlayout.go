@@ -0,0 +1,260 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	zwj       = '\u200d'
+	ellipsis  = "…"
+	lre, rle  = '\u202a', '\u202b'
+	pdf       = '\u202c'
+	lro, rlo  = '\u202d', '\u202e'
+	lri, rli  = '\u2066', '\u2067'
+	fsi, pdi  = '\u2068', '\u2069'
+	riFirst   = 0x1F1E6 // regional indicator symbol letter A
+	riLast    = 0x1F1FF
+	emojiMods = 0x1F3FB // skin tone modifiers 1F3FB..1F3FF
+)
+
+// wideRanges are the East Asian Wide (W) and Fullwidth (F) ranges
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x23F0, 0x23F0}, {0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE},
+	{0x26D4, 0x26D4}, {0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5},
+	{0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF},
+	{0xA960, 0xA97F}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x16FE0, 0x16FE4},
+	{0x17000, 0x18CFF}, {0x1B000, 0x1B2FF}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F251}, {0x1F300, 0x1F320},
+	{0x1F32D, 0x1F335}, {0x1F337, 0x1F37C}, {0x1F37E, 0x1F393}, {0x1F3A0, 0x1F3CA},
+	{0x1F3CF, 0x1F3D3}, {0x1F3E0, 0x1F3F0}, {0x1F3F4, 0x1F3F4}, {0x1F3F8, 0x1F43E},
+	{0x1F440, 0x1F440}, {0x1F442, 0x1F4FC}, {0x1F4FF, 0x1F53D}, {0x1F54B, 0x1F54E},
+	{0x1F550, 0x1F567}, {0x1F57A, 0x1F57A}, {0x1F595, 0x1F596}, {0x1F5A4, 0x1F5A4},
+	{0x1F5FB, 0x1F64F}, {0x1F680, 0x1F6C5}, {0x1F6CC, 0x1F6CC}, {0x1F6D0, 0x1F6D2},
+	{0x1F6D5, 0x1F6D7}, {0x1F6EB, 0x1F6EC}, {0x1F6F4, 0x1F6FC}, {0x1F7E0, 0x1F7EB},
+	{0x1F90C, 0x1F93A}, {0x1F93C, 0x1F945}, {0x1F947, 0x1F9FF}, {0x1FA70, 0x1FAFF},
+	{0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+func isWide(r rune) bool {
+	if r < 0x1100 {
+		return false
+	}
+	for _, rng := range wideRanges {
+		if r < rng[0] {
+			return false
+		}
+		if r <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// isPictographic approximates Extended_Pictographic: the emoji that ZWJ
+// sequences are made of
+func isPictographic(r rune) bool {
+	switch {
+	case r == 0xA9, r == 0xAE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139:
+		return true
+	case r >= 0x2194 && r <= 0x21AA, r >= 0x2300 && r <= 0x23FF:
+		return true
+	case r >= 0x2600 && r <= 0x27BF, r >= 0x2B05 && r <= 0x2B55:
+		return true
+	case r >= 0x1F000 && r <= 0x1FAFF && !(r >= riFirst && r <= riLast) && !(r >= emojiMods && r <= emojiMods+4):
+		return true
+	}
+	return false
+}
+
+func isBidiControl(r rune) bool {
+	return (r >= lre && r <= rlo) || (r >= lri && r <= pdi) || r == '\u200e' || r == '\u200f'
+}
+
+// isExtend returns true if r never starts a grapheme cluster
+func isExtend(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r == zwj, r == '\u200c':
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF: // variation selectors
+		return true
+	case r >= emojiMods && r <= emojiMods+4:
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // emoji tag sequences
+		return true
+	case r >= 0x1160 && r <= 0x11FF: // hangul jungseong and jongseong
+		return true
+	}
+	return false
+}
+
+// graphemes splits s into (extended) grapheme clusters. It covers combining
+// marks, variation selectors, emoji modifiers and ZWJ sequences, regional
+// indicator pairs, hangul jamo and CR LF.
+func graphemes(s string) []string {
+	clusters := make([]string, 0, len(s))
+	start := 0
+	var prev, base rune = -1, -1
+	ri := 0 // regional indicators in the current run
+	for i, r := range s {
+		brk := i > 0
+		switch {
+		case prev == '\r' && r == '\n':
+			brk = false
+		case isExtend(r):
+			brk = false
+		case prev == zwj && isPictographic(base) && isPictographic(r):
+			brk = false
+		case r >= riFirst && r <= riLast && prev >= riFirst && prev <= riLast && ri%2 == 1:
+			brk = false
+		}
+		if brk {
+			clusters = append(clusters, s[start:i])
+			start = i
+		}
+		if i == start {
+			base = r
+		}
+		if r >= riFirst && r <= riLast {
+			ri++
+		} else {
+			ri = 0
+		}
+		prev = r
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
+
+// clusterWidth returns the number of cells a grapheme cluster takes
+func clusterWidth(c string) int {
+	r, _ := utf8.DecodeRuneInString(c)
+	switch {
+	case r == utf8.RuneError && len(c) == 0:
+		return 0
+	case isBidiControl(r), r == zwj, r == '\u200b', r == '\ufeff', unicode.IsControl(r):
+		return 0
+	case isExtend(r):
+		return 0
+	case isWide(r):
+		return 2
+	case strings.ContainsRune(c, '\ufe0f'), strings.ContainsRune(c, zwj) && isPictographic(r):
+		return 2 // emoji presentation
+	case r >= riFirst && r <= riLast:
+		return 2
+	}
+	return 1
+}
+
+// textWidth returns the display width of s in cells
+func textWidth(s string) int {
+	w := 0
+	for _, c := range graphemes(s) {
+		w += clusterWidth(c)
+	}
+	return w
+}
+
+// truncateText shortens s to fit into max cells. It prefers to cut on word
+// boundaries, never splits a grapheme cluster, closes any bidi embedding or
+// isolate left open by the cut and appends an ellipsis.
+func truncateText(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if textWidth(s) <= max {
+		return s
+	}
+	budget := max - textWidth(ellipsis)
+	if budget <= 0 {
+		return ellipsis
+	}
+
+	clusters := graphemes(s)
+	w := 0
+	cut := 0       // hard cut: clusters[:cut] fit
+	lastBreak := 0 // the last word boundary that fits
+	for i, c := range clusters {
+		cw := clusterWidth(c)
+		if w+cw > budget {
+			break
+		}
+		w += cw
+		cut = i + 1
+		r, _ := utf8.DecodeRuneInString(c)
+		if i+1 < len(clusters) {
+			next, _ := utf8.DecodeRuneInString(clusters[i+1])
+			if unicode.IsSpace(next) || unicode.IsSpace(r) || isWide(r) || isWide(next) || r == '-' {
+				lastBreak = i + 1
+			}
+		}
+	}
+	if lastBreak > 0 && lastBreak >= cut/2 {
+		cut = lastBreak
+	}
+
+	out := strings.Join(clusters[:cut], "")
+	out = strings.TrimRightFunc(out, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(",;:-–—", r)
+	})
+	return balanceBidi(out) + ellipsis
+}
+
+// balanceBidi appends the PDF/PDI characters needed to close the embeddings,
+// overrides and isolates left open in s.
+func balanceBidi(s string) string {
+	stack := make([]rune, 0)
+	for _, r := range s {
+		switch r {
+		case lre, rle, lro, rlo, lri, rli, fsi:
+			stack = append(stack, r)
+		case pdf:
+			if n := len(stack); n > 0 && stack[n-1] != lri && stack[n-1] != rli && stack[n-1] != fsi {
+				stack = stack[:n-1]
+			}
+		case pdi:
+			// a PDI closes the last isolate and every embedding opened in it
+			if !containsIsolate(stack) {
+				continue
+			}
+			for n := len(stack); n > 0; n-- {
+				top := stack[n-1]
+				stack = stack[:n-1]
+				if top == lri || top == rli || top == fsi {
+					break
+				}
+			}
+		}
+	}
+	for n := len(stack) - 1; n >= 0; n-- {
+		switch stack[n] {
+		case lri, rli, fsi:
+			s += string(pdi)
+		default:
+			s += string(pdf)
+		}
+	}
+	return s
+}
+
+func containsIsolate(stack []rune) bool {
+	for _, r := range stack {
+		if r == lri || r == rli || r == fsi {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTextWidth(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"日本語", 6},                // CJK
+		{"한국어", 6},                // hangul syllables
+		{"\u1100\u1161\u11a8", 2}, // hangul jamo, one syllable
+		{"cafe\u0301", 4},         // combining acute accent
+		{"e\u0301\u0323", 1},      // two combining marks
+		{"👨\u200d👩\u200d👧\u200d👦", 2}, // ZWJ family
+		{"👩\u200d❤\ufe0f\u200d👨", 2},  // ZWJ couple with a variation selector
+		{"🏳\ufe0f\u200d🌈", 2},         // rainbow flag
+		{"👍\U0001f3fd", 2},            // skin tone modifier
+		{"🇯🇵🇫🇷", 4},                   // two flags
+		{"🇯🇵🇫", 4},                    // a flag and a lone regional indicator
+		{"a\u200d", 1},                // a ZWJ after a letter is not an emoji
+		{"a\u200db", 2},               // and doesn't join the letters
+		{"\u2067abc\u2069", 3},        // the bidi controls take no space
+		{"tab\tx", 4},
+	}
+	for _, tt := range tests {
+		if got := textWidth(tt.s); got != tt.width {
+			t.Errorf("textWidth(%q) = %d, want %d", tt.s, got, tt.width)
+		}
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"cafe\u0301", []string{"c", "a", "f", "e\u0301"}},
+		{"👨\u200d👩\u200d👧!", []string{"👨\u200d👩\u200d👧", "!"}},
+		{"🇯🇵🇫🇷", []string{"🇯🇵", "🇫🇷"}},
+		{"a\u200db", []string{"a\u200d", "b"}},
+		{"\r\n\n", []string{"\r\n", "\n"}},
+	}
+	for _, tt := range tests {
+		if got := graphemes(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("graphemes(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello", 0, ""},
+		{"hello", -1, ""},
+		{"hello", 1, "…"},
+		{"hello world again", 12, "hello world…"},
+		{"hello, world", 8, "hello…"},
+		{"日本語の辞書", 7, "日本語…"},
+		{"cafe\u0301 au lait", 5, "cafe\u0301…"},
+		{"👨\u200d👩\u200d👧 family", 3, "👨\u200d👩\u200d👧…"},
+		{"🇯🇵🇫🇷🇩🇪", 5, "🇯🇵🇫🇷…"},
+		// the embeddings and isolates left open by the cut are closed
+		{"a \u2067שלום עולם\u2069 b", 8, "a \u2067שלום\u2069…"},
+		{"\u202aone two three\u202c", 9, "\u202aone two\u202c…"},
+		{"\u2067a \u202bb c d e\u202c\u2069", 5, "\u2067a \u202bb\u202c\u2069…"},
+		// the closed ones are left alone
+		{"\u2066ab\u2069 cd ef gh", 8, "\u2066ab\u2069 cd…"},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.s, tt.max); got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+		if got := textWidth(truncateText(tt.s, tt.max)); tt.max >= 0 && got > tt.max {
+			t.Errorf("truncateText(%q, %d) is %d cells wide", tt.s, tt.max, got)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// synonymGroup is a sense of a thesaurus entry
+type synonymGroup struct {
+	PartOfSpeech string   `json:"partOfSpeech,omitempty"`
+	Synonyms     []string `json:"synonyms,omitempty"`
+	Antonyms     []string `json:"antonyms,omitempty"`
+}
+
+// thesaurus reads OpenOffice/LibreOffice MyThes thesaurus files.
+//
+// The .idx file starts with the encoding and the number of entries followed
+// by sorted "word|offset" lines. The offset points to the entry in the .dat
+// file:
+//
+//	word|2
+//	(noun)|synonym|synonym|antonym (antonym)
+//	(verb)|synonym|synonym (similar term)
+type thesaurus struct {
+	datPath  string
+	encoding string
+	idx      []byte
+	lines    []int // offsets of the index lines in idx
+}
+
+// thesaurusDirs are searched for th_<lang>*.dat files
+var thesaurusDirs = []string{
+	"$HOME/Library/Spelling",
+	"$HOME/Library/Application Support/LibreOffice/4/user/extensions",
+	"/Applications/LibreOffice.app/Contents/Resources/extensions",
+	"/usr/share/mythes",
+	"/usr/share/myspell/dicts",
+	"/usr/local/share/mythes",
+}
+
+// findThesaurus returns the .dat file of the language (e.g. en_US)
+func findThesaurus(lang string) string {
+	for _, dir := range thesaurusDirs {
+		dir = os.ExpandEnv(dir)
+		for _, pattern := range []string{"th_" + lang + "*.dat", "*/th_" + lang + "*.dat", "*/*/th_" + lang + "*.dat"} {
+			if matches, _ := filepath.Glob(path.Join(dir, pattern)); len(matches) > 0 {
+				sort.Strings(matches)
+				return matches[len(matches)-1]
+			}
+		}
+	}
+	return ""
+}
+
+// openThesaurus opens the thesaurus of the .dat file and its .idx file
+func openThesaurus(datPath string) (*thesaurus, error) {
+	idxPath := strings.TrimSuffix(datPath, ".dat") + ".idx"
+	idx, err := ioutil.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	t := &thesaurus{datPath: datPath, idx: idx}
+
+	start := 0
+	for n := 0; start < len(idx); n++ {
+		end := bytes.IndexByte(idx[start:], '\n')
+		if end == -1 {
+			end = len(idx)
+		} else {
+			end += start
+		}
+		switch n {
+		case 0:
+			t.encoding = strings.TrimSpace(string(idx[start:end]))
+		case 1:
+			// number of entries
+		default:
+			if end > start {
+				t.lines = append(t.lines, start)
+			}
+		}
+		start = end + 1
+	}
+	return t, nil
+}
+
+func (t *thesaurus) line(i int) (string, int64) {
+	start := t.lines[i]
+	end := bytes.IndexByte(t.idx[start:], '\n')
+	if end == -1 {
+		end = len(t.idx) - start
+	}
+	l := strings.TrimRight(string(t.idx[start:start+end]), "\r")
+	sep := strings.LastIndex(l, "|")
+	if sep == -1 {
+		return l, -1
+	}
+	offset, err := strconv.ParseInt(l[sep+1:], 10, 64)
+	if err != nil {
+		offset = -1
+	}
+	return t.decode(l[:sep]), offset
+}
+
+// offset returns the .dat offset of the word or its lowercase, -1 if it's
+// not found. The index is sorted by bytes.
+func (t *thesaurus) offset(word string) int64 {
+	for _, w := range []string{word, strings.ToLower(word)} {
+		i := sort.Search(len(t.lines), func(i int) bool {
+			k, _ := t.line(i)
+			return k >= w
+		})
+		if i < len(t.lines) {
+			if k, offset := t.line(i); k == w {
+				return offset
+			}
+		}
+	}
+	return -1
+}
+
+// Lookup returns the synonym groups of the word
+func (t *thesaurus) Lookup(word string) ([]synonymGroup, error) {
+	offset := t.offset(strings.TrimSpace(word))
+	if offset < 0 {
+		return nil, nil
+	}
+	fd, err := os.Open(t.datPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	if _, err := fd.Seek(offset, 0); err != nil {
+		return nil, err
+	}
+
+	rd := bufio.NewReader(fd)
+	head, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(strings.TrimSpace(head), "|")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("thesaurus: bad entry at %d: %q", offset, head)
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("thesaurus: bad entry at %d: %q", offset, head)
+	}
+
+	groups := make([]synonymGroup, 0, n)
+	for ; n > 0; n-- {
+		l, err := rd.ReadString('\n')
+		if l == "" && err != nil {
+			break
+		}
+		fields := strings.Split(strings.TrimSpace(t.decode(l)), "|")
+		g := synonymGroup{PartOfSpeech: strings.Trim(fields[0], "()- ")}
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			switch {
+			case f == "":
+			case strings.HasSuffix(f, "(antonym)"):
+				g.Antonyms = append(g.Antonyms, strings.TrimSpace(strings.TrimSuffix(f, "(antonym)")))
+			default:
+				// drop the (similar term), (generic term) ... notes
+				if i := strings.Index(f, " ("); i > 0 && strings.HasSuffix(f, ")") {
+					f = f[:i]
+				}
+				g.Synonyms = append(g.Synonyms, f)
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// decode converts the ISO8859-1 text of old thesaurus files to UTF-8
+func (t *thesaurus) decode(s string) string {
+	enc := strings.ToUpper(strings.Replace(t.encoding, "-", "", -1))
+	if enc != "ISO88591" && enc != "LATIN1" || utf8.ValidString(s) {
+		return s
+	}
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+	return string(runes)
+}
+
+var thes *thesaurus
+var thesPath *string
+
+// getThesaurus opens the configured thesaurus once, nil if there's none. It's
+// opened again if the config changes, e.g. in the daemon.
+func getThesaurus() *thesaurus {
+	p := conf.Thesaurus
+	if p == "" {
+		p = findThesaurus(language())
+	}
+	if thesPath != nil && *thesPath == p {
+		return thes
+	}
+	thesPath, thes = &p, nil
+	if p == "" {
+		return nil
+	}
+	t, err := openThesaurus(p)
+	if err != nil {
+		pb.Logger.Println(err)
+		return nil
+	}
+	thes = t
+	return thes
+}
+
+// synonyms returns the synonym groups of the word from the thesaurus
+func synonyms(word string) []synonymGroup {
+	t := getThesaurus()
+	if t == nil {
+		return nil
+	}
+	groups, err := t.Lookup(word)
+	if err != nil {
+		pb.Logger.Println(err)
+	}
+	return groups
+}
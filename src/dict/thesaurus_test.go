@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeThesaurus writes the entries ("word" to the lines of its groups) as
+// MyThes th_test.dat and .idx files in the encoding and returns the .dat
+func writeThesaurus(t *testing.T, dir, encoding string, entries map[string][]string) string {
+	words := make([]string, 0, len(entries))
+	for w := range entries {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+
+	var dat, idx bytes.Buffer
+	dat.WriteString(encoding + "\n")
+	fmt.Fprintf(&idx, "%s\n%d\n", encoding, len(words))
+	for _, w := range words {
+		fmt.Fprintf(&idx, "%s|%d\n", w, dat.Len())
+		fmt.Fprintf(&dat, "%s|%d\n", w, len(entries[w]))
+		for _, l := range entries[w] {
+			dat.WriteString(l + "\n")
+		}
+	}
+	p := path.Join(dir, "th_test.dat")
+	if err := ioutil.WriteFile(p, dat.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "th_test.idx"), idx.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestThesaurusLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thesaurus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	th, err := openThesaurus(writeThesaurus(t, dir, "UTF-8", map[string][]string{
+		"Paris": {"(noun)|capital of France|City of Light"},
+		"happy": {
+			"(adj)|felicitous (similar term)|glad|unhappy (antonym)",
+			"-|well-chosen|",
+		},
+		"sad":  {"(adj)|unhappy|happy (antonym)"},
+		"zoom": {"(verb)|rush"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		word string
+		want []synonymGroup
+	}{
+		{"happy", []synonymGroup{
+			{PartOfSpeech: "adj", Synonyms: []string{"felicitous", "glad"}, Antonyms: []string{"unhappy"}},
+			{Synonyms: []string{"well-chosen"}},
+		}},
+		// the lowercase is tried
+		{"Sad", []synonymGroup{{PartOfSpeech: "adj", Synonyms: []string{"unhappy"}, Antonyms: []string{"happy"}}}},
+		{"Paris", []synonymGroup{{PartOfSpeech: "noun", Synonyms: []string{"capital of France", "City of Light"}}}},
+		{" zoom ", []synonymGroup{{PartOfSpeech: "verb", Synonyms: []string{"rush"}}}},
+		// the misses
+		{"paris", nil},
+		{"hap", nil},
+		{"aaa", nil},
+		{"zzz", nil},
+	}
+	for _, tt := range tests {
+		got, err := th.Lookup(tt.word)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lookup(%q) = %#v, %v, want %#v", tt.word, got, err, tt.want)
+		}
+	}
+}
+
+func TestThesaurusOffset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thesaurus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	entries := make(map[string][]string)
+	for n := 0; n < 100; n++ {
+		entries[fmt.Sprintf("w%03d", n)] = []string{"(noun)|x"}
+	}
+	th, err := openThesaurus(writeThesaurus(t, dir, "UTF-8", entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(th.lines) != 100 {
+		t.Fatalf("the index has %d lines, want 100", len(th.lines))
+	}
+	dat, _ := ioutil.ReadFile(path.Join(dir, "th_test.dat"))
+	for _, w := range []string{"w000", "w042", "w099"} {
+		offset := th.offset(w)
+		if want := int64(bytes.Index(dat, []byte(w+"|"))); offset != want {
+			t.Errorf("offset(%q) = %d, want %d", w, offset, want)
+		}
+	}
+	for _, w := range []string{"w", "w0421", "w100", "v000"} {
+		if offset := th.offset(w); offset != -1 {
+			t.Errorf("offset(%q) = %d, want -1", w, offset)
+		}
+	}
+}
+
+func TestThesaurusLatin1(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thesaurus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// café and naïve in ISO-8859-1
+	cafe, naive := "caf\xe9", "na\xefve"
+	th, err := openThesaurus(writeThesaurus(t, dir, "ISO8859-1", map[string][]string{
+		cafe:  {"(noun)|coffee shop|bistro"},
+		naive: {"(adj)|ing\xe9nue|worldly (antonym)"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		word string
+		want []synonymGroup
+	}{
+		{"café", []synonymGroup{{PartOfSpeech: "noun", Synonyms: []string{"coffee shop", "bistro"}}}},
+		{"naïve", []synonymGroup{{PartOfSpeech: "adj", Synonyms: []string{"ingénue"}, Antonyms: []string{"worldly"}}}},
+		{"cafe", nil},
+	}
+	for _, tt := range tests {
+		got, err := th.Lookup(tt.word)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lookup(%q) = %#v, %v, want %#v", tt.word, got, err, tt.want)
+		}
+	}
+
+	// the UTF-8 text of a file that says it's ISO-8859-1 is kept
+	if got := th.decode("café"); got != "café" {
+		t.Errorf("decode(UTF-8) = %q", got)
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	. "github.com/nbjahan/go-launchbar"
+)
+
+// tuiDebounce is how long the TUI waits for the next keystroke before it
+// looks up the query
+const tuiDebounce = 150 * time.Millisecond
+
+// tuiKeys are the key bindings shown in the status line
+const tuiKeys = "↑↓ select  Tab details  ^Y copy word  ^D copy definition  ^B backend  Esc quit"
+
+type tuiResult struct {
+	word    string
+	summary string
+	entry   *Entry
+}
+
+type tuiResults struct {
+	query   string
+	results []tuiResult
+}
+
+// tuiLookup is a query for the lookup worker, canceled when the query changes
+type tuiLookup struct {
+	ctx   context.Context
+	query string
+}
+
+// tui is the state of the terminal UI. It's only touched by the main loop,
+// the lookups send their results back on a channel.
+type tui struct {
+	limit    int
+	query    []rune
+	results  []tuiResult
+	selected int
+	offset   int // first visible result
+	detail   bool
+	scroll   int // first visible line of the detail pane
+	status   string
+	busy     bool
+	backend  string
+	errors   chan error
+	width    int
+	height   int
+	out      bytes.Buffer
+}
+
+func tuiCommand(args []string) int {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	backend := fs.String("backend", "", "dictionary backend: "+strings.Join(backendNames(), ", ")+" (default: the backend config)")
+	lang := fs.String("lang", "", "spelling language, e.g. en_GB (default: the lang config)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dict tui [-backend name] [-lang code] [query]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	c, err := initCommand(*backend, *lang)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dict: %v\n", err)
+		return 2
+	}
+
+	restore, err := rawMode()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dict: tui needs a terminal: %v\n", err)
+		return 1
+	}
+	// alternate screen
+	os.Stdout.WriteString("\x1b[?1049h")
+	defer func() {
+		os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+		restore()
+	}()
+
+	t := &tui{
+		limit:   conf.Limit,
+		query:   []rune(strings.Join(fs.Args(), " ")),
+		backend: *backend,
+		errors:  make(chan error, 1),
+	}
+	if t.backend == "" {
+		t.backend = conf.Backend
+	}
+	if t.backend == "" {
+		t.backend = defaultBackend()
+	}
+	// the errors are shown in the status line, stderr would mess the screen
+	errorHandler = func(err error) {
+		select {
+		case t.errors <- err:
+		default:
+		}
+	}
+	defer func() { errorHandler = nil }()
+	t.resize()
+	t.run(c, conf.Lang)
+	return 0
+}
+
+func (t *tui) run(c *Config, lang string) {
+	keys := make(chan string)
+	go readKeys(keys)
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	results := make(chan tuiResults)
+	// the lookups run one at a time on a worker, the backend never gets two
+	// at once. The queue holds the next query only.
+	queue := make(chan tuiLookup, 1)
+	defer close(queue)
+	var lookups sync.WaitGroup
+	go lookupWorker(queue, results, &lookups, t.limit)
+	cancel := func() {}
+	debounce := time.NewTimer(0)
+	if len(t.query) == 0 {
+		debounce.Stop()
+	}
+	t.draw()
+	for {
+		select {
+		case <-winch:
+			t.resize()
+		case <-debounce.C:
+			cancel()
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			t.busy = true
+			select {
+			case <-queue:
+				// replaced before the worker got to it
+				lookups.Done()
+			default:
+			}
+			lookups.Add(1)
+			queue <- tuiLookup{ctx, string(t.query)}
+		case err := <-t.errors:
+			t.status = err.Error()
+		case r := <-results:
+			if r.query == string(t.query) {
+				t.results, t.selected, t.offset, t.scroll, t.busy = r.results, 0, 0, 0, false
+			}
+		case key, ok := <-keys:
+			if !ok {
+				cancel()
+				return
+			}
+			if key == "\x02" {
+				// no lookup may run while the backend is switched
+				cancel()
+				lookups.Wait()
+			}
+			changed, quit := t.handleKey(key, c, lang)
+			if quit {
+				cancel()
+				return
+			}
+			if changed {
+				cancel()
+				debounce.Reset(tuiDebounce)
+				if len(t.query) == 0 {
+					debounce.Stop()
+					t.results, t.busy = nil, false
+				}
+			}
+		}
+		t.draw()
+	}
+}
+
+// lookupWorker runs the queued lookups one after the other until the queue is
+// closed, and sends the results of the ones that are not canceled
+func lookupWorker(queue <-chan tuiLookup, results chan<- tuiResults, lookups *sync.WaitGroup, limit int) {
+	for l := range queue {
+		if l.ctx.Err() != nil {
+			lookups.Done()
+			continue
+		}
+		rs := make([]tuiResult, 0)
+		for _, row := range lookupContext(l.ctx, l.query, limit) {
+			e := parseEntry(row[1])
+			if e.Headword == "" {
+				e.Headword = row[0]
+			}
+			rs = append(rs, tuiResult{row[0], summary(e), e})
+		}
+		if l.ctx.Err() == nil {
+			select {
+			case results <- tuiResults{l.query, rs}:
+			case <-l.ctx.Done():
+			}
+		}
+		lookups.Done()
+	}
+}
+
+// handleKey updates the state for the key. changed is true if the query
+// changed.
+func (t *tui) handleKey(key string, c *Config, lang string) (changed, quit bool) {
+	t.status = ""
+	switch key {
+	case "\x03": // ^C
+		return false, true
+	case "\x1b":
+		if !t.detail {
+			return false, true
+		}
+		t.detail = false
+	case "\t", "\r":
+		t.detail = !t.detail && len(t.results) > 0
+		t.scroll = 0
+	case "up", "\x10": // ^P
+		if t.selected > 0 {
+			t.selected--
+			t.scroll = 0
+		}
+	case "down", "\x0e": // ^N
+		if t.selected < len(t.results)-1 {
+			t.selected++
+			t.scroll = 0
+		}
+	case "pgup":
+		if t.detail {
+			t.scroll -= t.height / 2
+		} else {
+			t.selected -= t.height / 2
+		}
+	case "pgdown":
+		if t.detail {
+			t.scroll += t.height / 2
+		} else {
+			t.selected += t.height / 2
+		}
+	case "\x7f", "\x08": // backspace
+		if len(t.query) > 0 {
+			t.query = t.query[:len(t.query)-1]
+			return true, false
+		}
+	case "\x15": // ^U
+		t.query = t.query[:0]
+		return true, false
+	case "\x19": // ^Y
+		if r, ok := t.current(); ok {
+			t.copy(r.word)
+		}
+	case "\x04": // ^D
+		if r, ok := t.current(); ok {
+			t.copy(strings.TrimRight(exportText(r.entry), "\n"))
+		}
+	case "\x02": // ^B
+		names := backendNames()
+		next := names[0]
+		for n, name := range names {
+			if name == t.backend && n+1 < len(names) {
+				next = names[n+1]
+			}
+		}
+		if err := useBackend(next, lang, c); err != nil {
+			t.status = err.Error()
+		} else {
+			t.backend = next
+			t.status = "backend: " + next
+			return true, false
+		}
+	default:
+		if r := []rune(key); len(r) == 1 && r[0] >= ' ' {
+			t.query = append(t.query, r[0])
+			return true, false
+		}
+	}
+	if t.selected >= len(t.results) {
+		t.selected = len(t.results) - 1
+	}
+	if t.selected < 0 {
+		t.selected = 0
+	}
+	if t.scroll < 0 {
+		t.scroll = 0
+	}
+	return false, false
+}
+
+func (t *tui) current() (tuiResult, bool) {
+	if t.selected < len(t.results) {
+		return t.results[t.selected], true
+	}
+	return tuiResult{}, false
+}
+
+// copy sets the terminal's clipboard with OSC 52, which also works over SSH
+func (t *tui) copy(s string) {
+	fmt.Fprintf(os.Stdout, "\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(s)))
+	t.status = fmt.Sprintf("copied %d characters", len([]rune(s)))
+}
+
+func (t *tui) resize() {
+	t.width, t.height = 80, 24
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	var w, h int
+	if out, err := cmd.Output(); err == nil {
+		// a terminal without a size (e.g. a serial line) reports 0 0
+		if fmt.Sscanf(string(out), "%d %d", &h, &w); w > 0 && h > 0 {
+			t.width, t.height = w, h
+		}
+	}
+}
+
+func (t *tui) line(s string) {
+	t.out.WriteString(truncateText(s, t.width))
+	t.out.WriteString("\x1b[K\r\n")
+}
+
+// draw redraws the screen: the prompt, the results and the detail pane of
+// the selected result, and the status line at the bottom
+func (t *tui) draw() {
+	t.out.Reset()
+	t.out.WriteString("\x1b[?25l\x1b[H")
+	t.line("dict› " + string(t.query))
+
+	rows := t.height - 3
+	detail := make([]string, 0)
+	if r, ok := t.current(); ok && t.detail {
+		detail = strings.Split(strings.TrimRight(exportText(r.entry), "\n"), "\n")
+		rows = len(t.results)
+		if rows > t.height/3 {
+			rows = t.height / 3
+		}
+	}
+	if t.selected < t.offset {
+		t.offset = t.selected
+	}
+	if t.selected >= t.offset+rows {
+		t.offset = t.selected - rows + 1
+	}
+	for n := t.offset; n < t.offset+rows; n++ {
+		if n >= len(t.results) {
+			if !t.detail {
+				t.line("")
+			}
+			continue
+		}
+		// the escapes are added after the text is cut to the width
+		r := t.results[n]
+		word := truncateText(r.word, t.width)
+		rest := ""
+		if space := t.width - textWidth(word) - 2; space > 0 && r.summary != "" {
+			rest = "  \x1b[2m" + truncateText(r.summary, space) + "\x1b[0m"
+		}
+		if n == t.selected {
+			word = "\x1b[7m" + word + "\x1b[27m"
+		}
+		t.out.WriteString(word + rest + "\x1b[K\r\n")
+	}
+	if t.detail {
+		t.line(strings.Repeat("─", t.width))
+		space := t.height - 3 - rows - 1
+		if t.scroll > len(detail)-space {
+			t.scroll = len(detail) - space
+		}
+		if t.scroll < 0 {
+			t.scroll = 0
+		}
+		for n := t.scroll; n < t.scroll+space; n++ {
+			if n < len(detail) {
+				t.line(detail[n])
+			} else {
+				t.line("")
+			}
+		}
+	}
+
+	status := t.status
+	if status == "" {
+		status = tuiKeys
+	}
+	if t.busy {
+		status = "looking up… " + status
+	}
+	t.out.WriteString("\x1b[2m")
+	count := fmt.Sprintf("%d results", len(t.results))
+	if len(t.results) == 1 {
+		count = "1 result"
+	}
+	t.line(count + " · " + source() + " · " + status)
+	t.out.WriteString("\x1b[0m\x1b[J")
+	// the cursor after the query
+	fmt.Fprintf(&t.out, "\x1b[1;%dH\x1b[?25h", textWidth("dict› "+string(t.query))+1)
+	os.Stdout.Write(t.out.Bytes())
+}
+
+// rawMode puts the terminal in raw mode with stty and returns the func that
+// restores it
+func rawMode() (func(), error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(state) }, nil
+}
+
+// readKeys reads the keys from stdin, the arrow and page keys are sent as
+// "up", "down", "pgup" and "pgdown". keys is closed when stdin is closed.
+func readKeys(keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		in := string(buf[:n])
+		for in != "" {
+			key := in[:1]
+			switch {
+			case strings.HasPrefix(in, "\x1b[A") || strings.HasPrefix(in, "\x1bOA"):
+				key = "up"
+				in = in[3:]
+			case strings.HasPrefix(in, "\x1b[B") || strings.HasPrefix(in, "\x1bOB"):
+				key = "down"
+				in = in[3:]
+			case strings.HasPrefix(in, "\x1b[5~"):
+				key = "pgup"
+				in = in[4:]
+			case strings.HasPrefix(in, "\x1b[6~"):
+				key = "pgdown"
+				in = in[4:]
+			case strings.HasPrefix(in, "\x1b["):
+				// other escape sequences
+				end := strings.IndexFunc(in[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e })
+				if end == -1 {
+					in = ""
+				} else {
+					in = in[2+end+1:]
+				}
+				continue
+			default:
+				r := []rune(in)
+				key = string(r[0])
+				in = string(r[1:])
+			}
+			keys <- key
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLookupWorkerSerializes(t *testing.T) {
+	var active, most int32
+	b := &fakeBackend{
+		words: map[string]string{"q4": "q4 | | noun the last query"},
+		wait: func(string) {
+			n := atomic.AddInt32(&active, 1)
+			for m := atomic.LoadInt32(&most); n > m && !atomic.CompareAndSwapInt32(&most, m, n); m = atomic.LoadInt32(&most) {
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+		},
+	}
+	done := useFakeBackend(b)
+	defer done()
+
+	queue := make(chan tuiLookup, 1)
+	results := make(chan tuiResults)
+	var lookups sync.WaitGroup
+	go lookupWorker(queue, results, &lookups, 10)
+
+	// the queries of fast typing, each one cancels the previous lookup
+	cancel := func() {}
+	for n := 0; n < 5; n++ {
+		cancel()
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		select {
+		case <-queue:
+			lookups.Done()
+		default:
+		}
+		lookups.Add(1)
+		queue <- tuiLookup{ctx, fmt.Sprintf("q%d", n)}
+		time.Sleep(time.Millisecond)
+	}
+	// a canceled lookup may still get its results through, the main loop
+	// ignores them
+	r := <-results
+	for r.query != "q4" {
+		r = <-results
+	}
+	lookups.Wait()
+	close(queue)
+	cancel()
+
+	if r.query != "q4" || len(r.results) != 1 || r.results[0].word != "q4" {
+		t.Errorf("results = %+v, want the definition of q4", r)
+	}
+	if most != 1 {
+		t.Errorf("%d lookups ran at once, want 1", most)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	. "github.com/nbjahan/go-launchbar"
+)
+
+// webDictionary is a website to look up the words the dictionaries don't
+// have. URL is a template with the placeholders {query} and {lang}, which are
+// query escaped unless an option says otherwise:
+//
+//	{query:path}            percent escaped for a path, %20 for spaces
+//	{query:raw}             not escaped
+//	{query:lower}           lower case
+//	{query:underscore}      spaces as _, like Wikipedia
+//	{query:hyphen}          spaces as -
+//	{lang:short}            the language without the region, en for en_US
+//
+// The options can be combined: {query:lower,hyphen,path}.
+type webDictionary struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Enabled bool   `json:"enabled"`
+}
+
+// defaultWebDictionaries is the webDictionaries config of a new install
+var defaultWebDictionaries = []webDictionary{
+	{"Merriam-Webster", "https://www.merriam-webster.com/dictionary/{query:path}", true},
+	{"Wiktionary", "https://{lang:short}.wiktionary.org/wiki/{query:underscore,path}", true},
+	{"Cambridge", "https://dictionary.cambridge.org/search/direct/?datasetsearch=english&q={query}", false},
+	{"Urban Dictionary", "https://www.urbandictionary.com/define.php?term={query}", false},
+}
+
+var placeholder = regexp.MustCompile(`\{(\w+)(?::([\w,]+))?\}`)
+
+// expandURL fills the template with the query and the language
+func expandURL(template, query, lang string) (string, error) {
+	var err error
+	out := placeholder.ReplaceAllStringFunc(template, func(m string) string {
+		parts := placeholder.FindStringSubmatch(m)
+		var s string
+		switch parts[1] {
+		case "query":
+			s = query
+		case "lang":
+			s = lang
+		default:
+			err = fmt.Errorf("unknown placeholder %s in %q", m, template)
+			return m
+		}
+		escape := url.QueryEscape
+		for _, opt := range strings.Split(parts[2], ",") {
+			switch opt {
+			case "":
+			case "path":
+				escape = url.PathEscape
+			case "raw":
+				escape = func(s string) string { return s }
+			case "lower":
+				s = strings.ToLower(s)
+			case "underscore":
+				s = strings.Join(strings.Fields(s), "_")
+			case "hyphen":
+				s = strings.Join(strings.Fields(s), "-")
+			case "short":
+				s = strings.SplitN(strings.SplitN(s, "_", 2)[0], "-", 2)[0]
+			default:
+				err = fmt.Errorf("unknown option %q in %s of %q", opt, m, template)
+			}
+		}
+		return escape(s)
+	})
+	return out, err
+}
+
+func indexWebDictionary(ds []webDictionary, name string) int {
+	for n, d := range ds {
+		if d.Name == name {
+			return n
+		}
+	}
+	return -1
+}
+
+// webItems returns the items that open the query in the enabled web
+// dictionaries
+func webItems(q string) []*Item {
+	items := make([]*Item, 0)
+	for _, d := range conf.WebDictionaries {
+		if !d.Enabled {
+			continue
+		}
+		u, err := expandURL(d.URL, q, language())
+		if err != nil {
+			pb.Logger.Println(err)
+			continue
+		}
+		items = append(items, NewItem(fmt.Sprintf("Look Up “%s” in %s", q, d.Name)).
+			SetSubtitle(u).
+			SetIcon("LinkArrowTemplate").
+			SetURL(u))
+	}
+	return items
+}
+
+// editWebDictionary toggles the web dictionary, or moves it up with ⌘ and
+// down with ⌥, or removes it with ⇧
+func editWebDictionary(c *Context) {
+	ds := append([]webDictionary(nil), conf.WebDictionaries...)
+	n := indexWebDictionary(ds, c.Input.FuncArg())
+	if n == -1 {
+		return
+	}
+	switch {
+	case c.Action.IsCommandKey():
+		if n > 0 {
+			ds[n-1], ds[n] = ds[n], ds[n-1]
+		}
+	case c.Action.IsOptionKey():
+		if n < len(ds)-1 {
+			ds[n], ds[n+1] = ds[n+1], ds[n]
+		}
+	case c.Action.IsShiftKey():
+		ds = append(ds[:n], ds[n+1:]...)
+	default:
+		ds[n].Enabled = !ds[n].Enabled
+	}
+	saveWebDictionaries(c, ds)
+}
+
+// addWebDictionary adds the web dictionary from a “name url” query
+func addWebDictionary(c *Context) {
+	name, u := parseWebDictionary(c.Input.FuncArg())
+	if name == "" {
+		return
+	}
+	ds := append([]webDictionary(nil), conf.WebDictionaries...)
+	if n := indexWebDictionary(ds, name); n != -1 {
+		ds[n].URL = u
+	} else {
+		ds = append(ds, webDictionary{name, u, true})
+	}
+	saveWebDictionaries(c, ds)
+}
+
+func saveWebDictionaries(c *Context, ds []webDictionary) {
+	c.Config.Set("webDictionaries", ds)
+	conf.WebDictionaries = ds
+	c.Action.ShowView("web")
+}
+
+// parseWebDictionary splits “Internal Wiki https://wiki/{query}” into the
+// name and the template. name is empty if there's no template.
+func parseWebDictionary(q string) (name, template string) {
+	fields := strings.Fields(q)
+	if len(fields) < 2 || !strings.Contains(fields[len(fields)-1], "://") {
+		return "", ""
+	}
+	return strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1]
+}
+
+func renderWebView(q string) {
+	v := pb.NewView("web")
+	i := v.NewItem("Back to Dictionary")
+	i.SetIcon("com.apple.Dictionary")
+	i.SetRun(ShowViewFunc("main"))
+
+	if name, u := parseWebDictionary(q); name != "" {
+		i = v.NewItem(fmt.Sprintf("Add “%s”", name))
+		i.SetSubtitle(u)
+		i.SetIcon("DictionaryOff")
+		i.Run("addWebDictionary", q)
+	}
+
+	for _, d := range conf.WebDictionaries {
+		if q != "" && !strings.Contains(strings.ToLower(d.Name), strings.ToLower(q)) {
+			continue
+		}
+		i = v.NewItem(d.Name)
+		if d.Enabled {
+			i.SetSubtitle("On — ↩ to turn off, ⌘↩ to move up, ⌥↩ to move down")
+			i.SetIcon("DictionaryOn")
+		} else {
+			i.SetSubtitle("Off — ↩ to turn on, ⌘↩ to move up, ⌥↩ to move down")
+			i.SetIcon("DictionaryOff")
+		}
+		i.SetModSubtitle("cmd", "Move up")
+		i.SetModSubtitle("alt", "Move down")
+		i.SetModSubtitle("shift", "Remove "+d.Name)
+		i.Run("editWebDictionary", d.Name)
+	}
+}
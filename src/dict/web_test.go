@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestExpandURL(t *testing.T) {
+	tests := []struct{ template, query, lang, want string }{
+		{"https://d.test/?q={query}", "ice cream & cake", "en_US", "https://d.test/?q=ice+cream+%26+cake"},
+		{"https://d.test/?q={query}", "café", "en_US", "https://d.test/?q=caf%C3%A9"},
+		{"https://d.test/{query:path}", "ice cream & cake", "en_US", "https://d.test/ice%20cream%20&%20cake"},
+		{"https://d.test/{query:path}", "a/b?c", "en_US", "https://d.test/a%2Fb%3Fc"},
+		{"https://d.test/{query:raw}", "a b/c", "en_US", "https://d.test/a b/c"},
+		{"https://d.test/{query:lower,hyphen,path}", " Ice  Cream ", "en_US", "https://d.test/ice-cream"},
+		{"https://d.test/wiki/{query:underscore,path}", "New  York", "en_US", "https://d.test/wiki/New_York"},
+		{"https://d.test/?q={query:underscore}", "rock & roll", "en_US", "https://d.test/?q=rock_%26_roll"},
+		{"https://{lang:short}.d.test/{query:path}", "hello", "en_US", "https://en.d.test/hello"},
+		{"https://{lang:short}.d.test/", "", "pt-BR", "https://pt.d.test/"},
+		{"https://{lang:short}.d.test/", "", "fr", "https://fr.d.test/"},
+		{"https://d.test/{lang}/{query}", "hi", "en_US", "https://d.test/en_US/hi"},
+		// the query is filled each time, and the other braces are kept
+		{"https://d.test/{query}#{query:raw}{}", "a b", "en", "https://d.test/a+b#a b{}"},
+		{"https://d.test/", "hello", "en", "https://d.test/"},
+	}
+	for _, tt := range tests {
+		got, err := expandURL(tt.template, tt.query, tt.lang)
+		if err != nil || got != tt.want {
+			t.Errorf("expandURL(%q, %q, %q) = %q, %v, want %q", tt.template, tt.query, tt.lang, got, err, tt.want)
+		}
+	}
+
+	for _, template := range []string{
+		"https://d.test/{word}",
+		"https://d.test/{query:upper}",
+		"https://d.test/{query:path,bogus}",
+		"https://d.test/{lang:raw,short}/{Query}",
+	} {
+		if got, err := expandURL(template, "hello", "en_US"); err == nil {
+			t.Errorf("expandURL(%q) = %q, want an error", template, got)
+		}
+	}
+
+	for _, d := range defaultWebDictionaries {
+		if _, err := expandURL(d.URL, "hello", "en_US"); err != nil {
+			t.Errorf("the default %s: %v", d.Name, err)
+		}
+	}
+}
+
+func TestParseWebDictionary(t *testing.T) {
+	tests := []struct{ q, name, template string }{
+		{"Collins https://www.collinsdictionary.com/dictionary/english/{query:path}", "Collins", "https://www.collinsdictionary.com/dictionary/english/{query:path}"},
+		{" Oxford  Learners  https://d.test/{query} ", "Oxford Learners", "https://d.test/{query}"},
+		{"https://d.test/{query}", "", ""},
+		{"Collins collinsdictionary.com", "", ""},
+	}
+	for _, tt := range tests {
+		if name, template := parseWebDictionary(tt.q); name != tt.name || template != tt.template {
+			t.Errorf("parseWebDictionary(%q) = %q, %q, want %q, %q", tt.q, name, template, tt.name, tt.template)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// personalWord is an entry of the user's personal word list
+type personalWord struct {
+	Word       string `json:"word"`
+	Definition string `json:"definition,omitempty"`
+}
+
+// wordList is the personal word list stored in the action's support path.
+// The spell path treats its entries as known words.
+type wordList struct {
+	path  string
+	Words []personalWord `json:"words"`
+}
+
+func loadWordList(dir string) *wordList {
+	w := &wordList{path: path.Join(dir, "words.json")}
+	if data, err := ioutil.ReadFile(w.path); err == nil {
+		json.Unmarshal(data, w)
+	}
+	return w
+}
+
+func (w *wordList) index(word string) int {
+	word = strings.TrimSpace(word)
+	for i, pw := range w.Words {
+		if strings.EqualFold(pw.Word, word) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the entry for word (case insensitive)
+func (w *wordList) Get(word string) (personalWord, bool) {
+	if w == nil {
+		return personalWord{}, false
+	}
+	if i := w.index(word); i != -1 {
+		return w.Words[i], true
+	}
+	return personalWord{}, false
+}
+
+// Has returns true if the word is in the list
+func (w *wordList) Has(word string) bool {
+	_, ok := w.Get(word)
+	return ok
+}
+
+// Add adds or updates the word with an optional one-line definition and
+// saves the list
+func (w *wordList) Add(word, definition string) error {
+	word = strings.TrimSpace(word)
+	definition = strings.Join(strings.Fields(definition), " ")
+	if word == "" {
+		return nil
+	}
+	if i := w.index(word); i != -1 {
+		w.Words[i].Definition = definition
+	} else {
+		w.Words = append(w.Words, personalWord{word, definition})
+	}
+	sort.Sort(byWord(w.Words))
+	return w.save()
+}
+
+// List returns the words of the list
+func (w *wordList) List() []string {
+	words := make([]string, len(w.Words))
+	for i, pw := range w.Words {
+		words[i] = pw.Word
+	}
+	return words
+}
+
+// Remove removes the word from the list and saves it
+func (w *wordList) Remove(word string) error {
+	i := w.index(word)
+	if i == -1 {
+		return nil
+	}
+	w.Words = append(w.Words[:i], w.Words[i+1:]...)
+	return w.save()
+}
+
+func (w *wordList) save() error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(w.path, data, 0664); err != nil {
+		return fmt.Errorf("saving the personal words: %v", err)
+	}
+	return nil
+}
+
+// parseWordDefinition splits "word = definition" queries used to add a word
+// with a custom definition.
+func parseWordDefinition(q string) (string, string) {
+	parts := strings.SplitN(q, "=", 2)
+	if len(parts) == 1 {
+		return strings.TrimSpace(q), ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
+
+type byWord []personalWord
+
+func (o byWord) Len() int           { return len(o) }
+func (o byWord) Swap(i, j int)      { o[i], o[j] = o[j], o[i] }
+func (o byWord) Less(i, j int) bool { return strings.ToLower(o[i].Word) < strings.ToLower(o[j].Word) }
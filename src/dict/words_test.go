@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/nbjahan/go-launchbar/launchbartest"
+)
+
+func TestWordList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := loadWordList(dir)
+	for _, add := range [][2]string{{"zeitgeist", ""}, {" Apple ", "a  fruit\n"}, {"apple", "the fruit"}, {"", "nothing"}} {
+		if err := w.Add(add[0], add[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []personalWord{{"Apple", "the fruit"}, {"zeitgeist", ""}}
+	if !reflect.DeepEqual(w.Words, want) {
+		t.Errorf("the words are %+v, want %+v", w.Words, want)
+	}
+	if !w.Has("APPLE") || !w.Has(" zeitgeist") || w.Has("apples") {
+		t.Error("Has is not case insensitive on the trimmed word")
+	}
+
+	// the list is saved
+	if got := loadWordList(dir).Words; !reflect.DeepEqual(got, want) {
+		t.Errorf("the saved words are %+v, want %+v", got, want)
+	}
+	if err := w.Remove("apple"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Remove("apple"); err != nil {
+		t.Errorf("removing a missing word: %v", err)
+	}
+	if got := loadWordList(dir).List(); !reflect.DeepEqual(got, []string{"zeitgeist"}) {
+		t.Errorf("the saved words after Remove are %q", got)
+	}
+
+	// and the errors are returned
+	w = loadWordList(path.Join(dir, "missing"))
+	if err := w.Add("hello", ""); err == nil {
+		t.Error("Add in a missing folder didn't fail")
+	}
+	if err := w.Remove("hello"); err == nil {
+		t.Error("Remove in a missing folder didn't fail")
+	}
+}
+
+func TestParseWordDefinition(t *testing.T) {
+	tests := []struct{ q, word, def string }{
+		{"hello", "hello", ""},
+		{" hello = a greeting ", "hello", "a greeting"},
+		{"e=mc2 = a formula", "e", "mc2 = a formula"},
+		{"= nothing", "", "nothing"},
+	}
+	for _, tt := range tests {
+		if word, def := parseWordDefinition(tt.q); word != tt.word || def != tt.def {
+			t.Errorf("parseWordDefinition(%q) = %q, %q, want %q, %q", tt.q, word, def, tt.word, tt.def)
+		}
+	}
+}
+
+func TestPersonalWordItems(t *testing.T) {
+	lb, done := newTestAction(t, &fakeBackend{}, `{"backend": "fake"}`)
+	defer done()
+	if err := ioutil.WriteFile(path.Join(lb.SupportPath, "words.json"), []byte(`{"words": [{"word": "livedic"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// each item is run before the next query
+	tests := []struct{ q, title, fn string }{
+		{"livedic = the action", "Update “livedic” in Personal Words", "addWord"},
+		{"livedic", "Remove “livedic” from Personal Words", "removeWord"},
+		{"zeitgeist = the spirit of the time", "Add “zeitgeist” to Personal Words", "addWord"},
+	}
+	for _, tt := range tests {
+		items, err := lb.Run(runAction, tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, i := range items {
+			if fn := i.Item().FuncName; fn == "addWord" || fn == "removeWord" {
+				found = true
+				if i.Item().Title != tt.title || fn != tt.fn {
+					t.Errorf("%q has the item %q running %s, want %q running %s", tt.q, i.Item().Title, fn, tt.title, tt.fn)
+				}
+				if _, err := lb.Run(runAction, launchbartest.ItemArg(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%q has no item for the personal words", tt.q)
+		}
+	}
+
+	want := []personalWord{{"zeitgeist", "the spirit of the time"}}
+	if got := loadWordList(lb.SupportPath).Words; !reflect.DeepEqual(got, want) {
+		t.Errorf("the personal words are %+v, want %+v", got, want)
+	}
+}
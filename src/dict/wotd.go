@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// levelWord is a word of the word of the day list with its difficulty level
+// (1 easy, 2 medium, 3 hard).
+type levelWord struct {
+	Word  string
+	Level int
+}
+
+var levelNames = map[string]int{"easy": 1, "medium": 2, "hard": 3}
+
+// defaultWordOfTheDayList is used when the wordOfTheDayList config is empty
+var defaultWordOfTheDayList = []levelWord{
+	{"candid", 1}, {"diligent", 1}, {"eager", 1}, {"frugal", 1}, {"humble", 1},
+	{"keen", 1}, {"lucid", 1}, {"mellow", 1}, {"novice", 1}, {"prudent", 1},
+	{"serene", 1}, {"vivid", 1}, {"wary", 1}, {"zeal", 1}, {"brisk", 1},
+	{"ambivalent", 2}, {"benevolent", 2}, {"cogent", 2}, {"dogmatic", 2}, {"ephemeral", 2},
+	{"fastidious", 2}, {"gregarious", 2}, {"hubris", 2}, {"innocuous", 2}, {"laconic", 2},
+	{"meticulous", 2}, {"nostalgia", 2}, {"ostensible", 2}, {"pragmatic", 2}, {"resilient", 2},
+	{"tenacious", 2}, {"ubiquitous", 2}, {"venerable", 2}, {"whimsical", 2}, {"zealous", 2},
+	{"abstruse", 3}, {"bellicose", 3}, {"circumlocution", 3}, {"defenestrate", 3}, {"perspicacious", 3},
+	{"grandiloquent", 3}, {"impecunious", 3}, {"juxtapose", 3}, {"lugubrious", 3}, {"mellifluous", 3},
+	{"obsequious", 3}, {"pusillanimous", 3}, {"quixotic", 3}, {"recalcitrant", 3}, {"sesquipedalian", 3},
+	{"tintinnabulation", 3}, {"obstreperous", 3}, {"vituperative", 3}, {"sycophant", 3}, {"petrichor", 3},
+}
+
+// loadLevelWords reads a word list file: one word per line, optionally
+// followed by a tab and its level (1-3 or easy, medium, hard). Lines starting
+// with # are ignored.
+func loadLevelWords(p string) ([]levelWord, error) {
+	fd, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	words := make([]levelWord, 0)
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		w := levelWord{Word: strings.TrimSpace(fields[0])}
+		if len(fields) > 1 {
+			w.Level = parseLevel(fields[1])
+		}
+		words = append(words, w)
+	}
+	return words, scanner.Err()
+}
+
+// parseLevel returns the level of "1".."3" or "easy", "medium", "hard", 0 if
+// it's not set.
+func parseLevel(s string) int {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if n, ok := levelNames[s]; ok {
+		return n
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// pickWordOfTheDay deterministically picks a word for the date. Words of
+// other levels are skipped when level is not 0.
+func pickWordOfTheDay(words []levelWord, level int, date time.Time) string {
+	candidates := make([]string, 0, len(words))
+	for _, w := range words {
+		if level == 0 || w.Level == 0 || w.Level == level {
+			candidates = append(candidates, w.Word)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(date.Format("2006-01-02")))
+	return candidates[h.Sum32()%uint32(len(candidates))]
+}
+
+// nextMidnight returns the start of the next day in t's location
+func nextMidnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
+
+// wordOfTheDayPick is the cached pick of the day
+type wordOfTheDayPick struct {
+	Word  string `json:"word"`
+	List  string `json:"list"`
+	Level string `json:"level"`
+}
+
+// wordOfTheDay returns today's word. The pick is cached until local midnight
+// so it stays the same for the whole day, unless the list or the level is
+// changed.
+func wordOfTheDay() string {
+	list := conf.WordOfTheDayList
+	level := conf.WordOfTheDayLevel
+	var pick wordOfTheDayPick
+	if _, err := pb.Cache.Get("wordOfTheDay", &pick); err == nil && pick.Word != "" && pick.List == list && pick.Level == level {
+		return pick.Word
+	}
+
+	words := defaultWordOfTheDayList
+	if list != "" {
+		lw, err := loadLevelWords(os.ExpandEnv(list))
+		if err != nil {
+			pb.Logger.Println(err)
+		} else {
+			words = lw
+		}
+	}
+	now := time.Now()
+	pick = wordOfTheDayPick{pickWordOfTheDay(words, parseLevel(level), now), list, level}
+	if pick.Word != "" {
+		pb.Cache.Set("wordOfTheDay", pick, nextMidnight(now).Sub(now))
+	}
+	return pick.Word
+}